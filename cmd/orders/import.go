@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/srKazuya/ordersPET/internal/config"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	orderImporter "github.com/srKazuya/ordersPET/internal/service/importer"
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/postgres"
+)
+
+// runImport implements `orders import [flags] FILE...`: a bulk load of NDJSON
+// order files straight into PostgreSQL. Interrupted imports resume from the
+// last committed batch when run again with the same files.
+func runImport(log *slog.Logger, cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: orders import [flags] FILE...")
+		fs.PrintDefaults()
+	}
+	batchSize := fs.Int("batch", orderImporter.DefaultBatchSize, "orders per COPY batch and progress checkpoint")
+	restart := fs.Bool("restart", false, "ignore saved progress and import files from the beginning")
+	final := fs.Bool("final", false, "files are complete: also import a last line without a trailing newline")
+	rejectsPath := fs.String("rejects", "", "write rejected lines as NDJSON to this file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if cfg.Storage.Driver != storage.DriverPostgres {
+		log.Error("import requires the postgres storage driver", slog.String("driver", cfg.Storage.Driver))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pg, err := postgres.New(postgresConfig(cfg))
+	if err != nil {
+		log.Error("failed to open storage", sl.Err(err))
+		return 1
+	}
+	defer pg.Close()
+
+	var rejects io.Writer
+	if *rejectsPath != "" {
+		f, err := os.OpenFile(*rejectsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Error("failed to open rejects file", sl.Err(err))
+			return 1
+		}
+		defer f.Close()
+		rejects = f
+	}
+
+	importer := orderImporter.New(log, pg, orderImporter.Config{
+		BatchSize: *batchSize,
+		Restart:   *restart,
+		Final:     *final,
+		Rejects:   rejects,
+	})
+
+	for _, path := range fs.Args() {
+		_, err := importer.ImportFile(ctx, path)
+		if errors.Is(err, context.Canceled) {
+			log.Warn("import interrupted, run again to resume", slog.String("file", path))
+			return 130
+		}
+		if err != nil {
+			log.Error("import failed", slog.String("file", path), sl.Err(err))
+			return 1
+		}
+	}
+
+	return 0
+}
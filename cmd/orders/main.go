@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -11,17 +13,38 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
+	"github.com/srKazuya/ordersPET/internal/cache"
 	"github.com/srKazuya/ordersPET/internal/config"
-	getter "github.com/srKazuya/ordersPET/internal/service/getter"
+	orderDeleter "github.com/srKazuya/ordersPET/internal/service/deleter"
+	orderGetter "github.com/srKazuya/ordersPET/internal/service/getter"
+	orderOutbox "github.com/srKazuya/ordersPET/internal/service/outbox"
+	orderPublisher "github.com/srKazuya/ordersPET/internal/service/publisher"
 	saver "github.com/srKazuya/ordersPET/internal/service/saver"
+	orderStatus "github.com/srKazuya/ordersPET/internal/service/status"
 
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/batch"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/cachestats"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/dlq"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/export"
 	"github.com/srKazuya/ordersPET/internal/http-server/handlers/get"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/health"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/list"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/remove"
 	"github.com/srKazuya/ordersPET/internal/http-server/handlers/save"
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/status"
 	nwLogger "github.com/srKazuya/ordersPET/internal/http-server/middleware/nwLogger"
+	promMetrics "github.com/srKazuya/ordersPET/internal/http-server/middleware/promMetrics"
 	kafka "github.com/srKazuya/ordersPET/internal/kafka"
 
+	"github.com/srKazuya/ordersPET/internal/lib/lifecycle"
 	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/tracing"
+	"github.com/srKazuya/ordersPET/internal/metrics"
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/memory"
 	"github.com/srKazuya/ordersPET/internal/storage/postgres"
 )
 
@@ -34,44 +57,82 @@ const (
 var address []string
 
 func main() {
-	fmt.Println("loaded config OK")
 	cfg := config.MustLoad()
 
 	log := setupLogger(cfg.Env)
 	log = log.With(slog.String("env", cfg.Env))
 
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(log, cfg, os.Args[2:]))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		FilePath:    cfg.Tracing.FilePath,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Error("failed to set up tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
 	log.Info("init server", slog.String("address", cfg.Address))
 	log.Debug("log debug mode enabl;ed")
 
-	pgConfig := postgres.Config{
-		DSN: fmt.Sprintf("host=%s user=%s port=%s password=%s dbname=%s sslmode=%s",
-			cfg.DataBase.Host,
-			cfg.DataBase.User,
-			cfg.DataBase.Port,
-			cfg.DataBase.Password,
-			cfg.DataBase.Dbname,
-			cfg.DataBase.Sslmode,
-		),
+	partitionKey, err := storage.ParsePartitionKey(cfg.Kafka.PartitionKey)
+	if err != nil {
+		log.Error("invalid kafka partition key", sl.Err(err))
+		os.Exit(1)
 	}
 
-	storage, err := postgres.New(pgConfig)
-	switch {
-	case errors.Is(err, postgres.ErrOpenDB):
-		log.Error("failed to connect to DB", sl.Err(err))
-		os.Exit(1)
-	case errors.Is(err, postgres.ErrMigration):
-		log.Error("migartion failed", sl.Err(err))
-		os.Exit(1)
-	case err != nil:
-		log.Error("unexpected error", sl.Err(err))
+	driver, err := storage.ParseDriver(cfg.Storage.Driver)
+	if err != nil {
+		log.Error("invalid storage driver", sl.Err(err))
 		os.Exit(1)
 	}
 
+	// pg stays nil with the memory driver; outbox needs PostgreSQL.
+	var (
+		repo storage.Repository
+		pg   *postgres.Storage
+	)
+	switch driver {
+	case storage.DriverMemory:
+		if cfg.Outbox.Enabled {
+			log.Error("outbox requires the postgres storage driver")
+			os.Exit(1)
+		}
+		log.Warn("using in-memory storage, orders are lost on restart")
+		repo = memory.New()
+	default:
+		pg, err = postgres.New(postgresConfig(cfg))
+		switch {
+		case errors.Is(err, postgres.ErrOpenDB):
+			log.Error("failed to connect to DB", sl.Err(err))
+			os.Exit(1)
+		case errors.Is(err, postgres.ErrMigration):
+			log.Error("migartion failed", sl.Err(err))
+			os.Exit(1)
+		case err != nil:
+			log.Error("unexpected error", sl.Err(err))
+			os.Exit(1)
+		}
+		metrics.RegisterDB(pg.DB())
+		repo = pg
+	}
+
 	for _, ad := range cfg.Kafka.Brokers {
 		address = append(address, ad)
 	}
 
-	p, err := kafka.NewProducer(log, address)
+	p, err := kafka.NewProducer(log, address, kafka.ProducerConfig{
+		Source:    cfg.Tracing.ServiceName,
+		QueueSize: cfg.Kafka.Producer.QueueSize,
+		Linger:    cfg.Kafka.Producer.Linger,
+	})
 	switch {
 	case errors.Is(err, kafka.ErrCreateProducer):
 		log.Error("failed to create producer", sl.Err(err))
@@ -79,9 +140,74 @@ func main() {
 		log.Error("iknown kafka error")
 	}
 
-	saver := saver.New(log, storage)
+	var deadLetter *kafka.DeadLetter
+	if p != nil {
+		deadLetter = kafka.NewDeadLetter(p, address, cfg.Kafka.DLQTopic, cfg.Kafka.Topic)
+	}
+
+	orderCache := cache.New(cache.Config{
+		MaxEntries: cfg.Cache.MaxEntries,
+		MaxBytes:   cfg.Cache.MaxBytes,
+		TTL:        cfg.Cache.TTL,
+	})
+
+	saver := saver.New(log, repo, orderCache)
+
+	retry := kafka.RetryPolicy{
+		InitialInterval: cfg.Kafka.Retry.InitialInterval,
+		MaxInterval:     cfg.Kafka.Retry.MaxInterval,
+		Multiplier:      cfg.Kafka.Retry.Multiplier,
+		MaxAttempts:     cfg.Kafka.Retry.MaxAttempts,
+	}
+
+	publishMode, err := orderPublisher.ParseMode(cfg.Kafka.Producer.Mode)
+	if err != nil {
+		log.Error("invalid producer mode", sl.Err(err))
+		os.Exit(1)
+	}
+	if cfg.Outbox.Enabled {
+		publishMode = orderPublisher.ModeOutbox
+	}
+
+	var producer orderPublisher.Producer
+	if p != nil {
+		producer = p
+	}
+	var outbox orderPublisher.Outbox
+	if pg != nil {
+		outbox = pg
+	}
+	publisher := orderPublisher.New(log, producer, outbox, orderPublisher.Config{
+		Mode:   publishMode,
+		Topic:  cfg.Kafka.Topic,
+		Key:    partitionKey,
+		Source: cfg.Tracing.ServiceName,
+	})
+
+	var relay *orderOutbox.Relay
+	if cfg.Outbox.Enabled && p != nil {
+		relay = orderOutbox.New(log, pg, p, orderOutbox.Config{
+			Interval:  cfg.Outbox.Interval,
+			BatchSize: cfg.Outbox.BatchSize,
+			Retention: cfg.Outbox.Retention,
+			Retry:     retry,
+		})
+		go relay.Start()
+	} else if cfg.Outbox.Enabled {
+		log.Warn("kafka producer is unavailable, outbox messages will wait until restart")
+	}
+
+	events := kafka.NewDispatcher()
+	events.Handle(kafka.EventOrderCreated, func(ctx context.Context, env kafka.Envelope) error {
+		return saver.SaveOrder(ctx, env.Payload)
+	})
+	// Cache invalidation is handled by the fan-out consumer below, which sees
+	// every change; in the shared group only one replica would.
+	ignore := func(context.Context, kafka.Envelope) error { return nil }
+	events.Handle(kafka.EventOrderStatusChanged, ignore)
+	events.Handle(kafka.EventOrderDeleted, ignore)
 
-	c, err := kafka.NewConsumer(saver, log, address, cfg.Topic, cfg.ConsumerGroup)
+	c, err := kafka.NewConsumer(events, log, address, cfg.Topic, cfg.ConsumerGroup, deadLetter, retry, cfg.Kafka.PartitionQueue)
 	if err != nil {
 		switch {
 		case errors.Is(err, kafka.ErrCreateConsumer):
@@ -97,11 +223,112 @@ func main() {
 		c.Start(log)
 	}()
 
-	getter := getter.New(log, storage)
+	// Every replica reads the topic in a group of its own to drop the orders
+	// changed through any replica from its cache.
+	invalidations := kafka.NewDispatcher()
+	invalidations.Handle(kafka.EventOrderStatusChanged, func(ctx context.Context, env kafka.Envelope) error {
+		var change kafka.StatusChangedPayload
+		if err := json.Unmarshal(env.Payload, &change); err != nil {
+			log.Warn("invalid status change event", slog.String("event_id", env.EventID), sl.Err(err))
+			return nil
+		}
+		orderCache.Delete(change.OrderUID)
+		return nil
+	})
+	invalidations.Handle(kafka.EventOrderDeleted, func(ctx context.Context, env kafka.Envelope) error {
+		var deleted kafka.OrderDeletedPayload
+		if err := json.Unmarshal(env.Payload, &deleted); err != nil {
+			log.Warn("invalid order deleted event", slog.String("event_id", env.EventID), sl.Err(err))
+			return nil
+		}
+		orderCache.Delete(deleted.OrderUID)
+		return nil
+	})
+	invalidations.Default(ignore)
+
+	// stopBackground registers everything running besides the HTTP server, in
+	// the order it must stop; ic is nil until it is created.
+	var ic *kafka.Consumer
+	stopBackground := func(lc *lifecycle.Manager) {
+		lc.Add("kafka consumer", c.Stop)
+		if ic != nil {
+			lc.Add("kafka cache consumer", ic.Stop)
+		}
+		if relay != nil {
+			lc.Add("outbox relay", relay.Stop)
+		}
+		if p != nil {
+			lc.Add("kafka producer", p.Close)
+		}
+		lc.Add(driver, func(context.Context) error { return repo.Close() })
+		lc.Add("tracing", shutdownTracing)
+	}
+
+	cacheGroup := cacheConsumerGroup(cfg.ConsumerGroup)
+	ic, err = kafka.NewFanoutConsumer(invalidations, log, address, cfg.Topic, cacheGroup, retry)
+	if err != nil {
+		log.Error("failed to create Kafka cache consumer", slog.String("group", cacheGroup), sl.Err(err))
+		// The order consumer is already running: stop it properly so its
+		// offsets are committed, then release the rest.
+		lc := lifecycle.New(log, cfg.ShutdownTimeout)
+		stopBackground(lc)
+		_ = lc.Shutdown()
+		os.Exit(1)
+	}
+	go func() {
+		ic.Start(log)
+	}()
+
+	metrics.RegisterCache(orderCache.Stats)
+
+	getter := orderGetter.New(log, repo, orderCache)
+
+	readiness := []health.Check{
+		{Name: driver, Required: true, Probe: repo.Ping},
+	}
+	if pg != nil {
+		readiness = append(readiness, health.Check{Name: "migrations", Required: true, Probe: pg.CheckSchema})
+	}
+	// With the outbox, saves only need the database and Kafka may be down;
+	// it is still reported but does not take the replica out of rotation.
+	kafkaRequired := !cfg.Outbox.Enabled
+	readiness = append(readiness, health.Check{Name: "kafka_consumer", Required: kafkaRequired, Probe: c.Ping})
+	readiness = append(readiness, health.Check{Name: "kafka_cache_consumer", Required: kafkaRequired, Probe: ic.Ping})
+	if p != nil {
+		readiness = append(readiness, health.Check{Name: "kafka_producer", Required: kafkaRequired, Probe: p.Ping})
+	} else {
+		readiness = append(readiness, health.Check{Name: "kafka_producer", Required: kafkaRequired, Probe: func(context.Context) error {
+			return kafka.ErrCreateProducer
+		}})
+	}
+
+	if cfg.Cache.Warmup.Enabled {
+		readiness = append(readiness, health.Check{Name: "cache_warmup", Required: true, Probe: health.Flag(getter.Warmed)})
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Cache.Warmup.Timeout)
+			defer cancel()
+
+			_, err := getter.Warm(ctx, repo, orderGetter.WarmupOptions{
+				Limit:     cfg.Cache.Warmup.Limit,
+				Window:    cfg.Cache.Warmup.Window,
+				BatchSize: cfg.Cache.Warmup.BatchSize,
+			})
+			if err != nil {
+				log.Error("cache warm-up failed", sl.Err(err))
+			}
+		}()
+	}
+
+	// Closed on shutdown: exports may run far longer than the shutdown timeout.
+	stopExports := make(chan struct{})
+
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
+	router.Use(otelhttp.NewMiddleware("orders-http"))
 	router.Use(nwLogger.New(log))
+	router.Use(promMetrics.New())
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
@@ -109,8 +336,35 @@ func main() {
 		http.ServeFile(w, r, "./static/index.html")
 	})
 
-	router.Post("/save", save.New(log, p, cfg.Kafka.Topic))
+	router.Get("/healthz", health.NewLiveness())
+	router.Get("/readyz", health.NewReadiness(log, readiness...))
+
+	router.Post("/save", save.New(log, publisher))
+	router.Post("/orders/batch", batch.New(log, publisher, cfg.HTTPServer.BatchMaxOrders, cfg.HTTPServer.BatchMaxBytes, cfg.HTTPServer.BatchTimeout, cfg.HTTPServer.Timeout))
+	router.Get("/orders", list.New(log, repo))
+	router.Get("/orders/export", export.New(log, repo, cfg.HTTPServer.Timeout, stopExports))
 	router.Get("/orders/{order_uid}", get.New(log, getter))
+	// With the outbox, status changes and deletions are announced in their own
+	// transaction, so other replicas hear of them even while Kafka is down.
+	var (
+		statusOutbox orderStatus.OutboxUpdater
+		deleteOutbox orderDeleter.OutboxDeleter
+	)
+	if cfg.Outbox.Enabled {
+		statusOutbox, deleteOutbox = pg, pg
+	}
+	router.Patch("/orders/{order_uid}/status", status.New(log, orderStatus.New(log, repo, statusOutbox, orderCache, publisher)))
+	router.Delete("/orders/{order_uid}", remove.New(log, orderDeleter.New(log, repo, deleteOutbox, orderCache, publisher)))
+	router.Get("/cache/stats", cachestats.New(orderCache))
+	router.Handle("/metrics", metrics.Handler())
+
+	if deadLetter != nil {
+		router.Route("/dlq/messages", func(r chi.Router) {
+			r.Get("/", dlq.NewList(log, deadLetter))
+			r.Get("/{partition}/{offset}", dlq.NewGet(log, deadLetter))
+			r.Post("/{partition}/{offset}/redrive", dlq.NewRedrive(log, deadLetter))
+		})
+	}
 
 	srv := &http.Server{
 		Addr:         cfg.Address,
@@ -119,6 +373,7 @@ func main() {
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
+	srv.RegisterOnShutdown(func() { close(stopExports) })
 
 	go func() {
 		log.Info("starting HTTP server", slog.String("address", cfg.Address))
@@ -134,7 +389,38 @@ func main() {
 
 	log.Info("shutting down server...")
 
-	c.Stop()
+	lc := lifecycle.New(log, cfg.ShutdownTimeout)
+	lc.Add("http server", srv.Shutdown)
+	stopBackground(lc)
+
+	if err := lc.Shutdown(); err != nil {
+		log.Error("shutdown completed with errors", sl.Err(err))
+		os.Exit(1)
+	}
+}
+
+// cacheConsumerGroup returns a group id unique to this replica, so it gets
+// every cache invalidation rather than a share of them. The hostname keeps it
+// stable across restarts of the same pod.
+func cacheConsumerGroup(group string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = uuid.NewString()
+	}
+	return group + "-cache-" + host
+}
+
+func postgresConfig(cfg *config.Config) postgres.Config {
+	return postgres.Config{
+		DSN: fmt.Sprintf("host=%s user=%s port=%s password=%s dbname=%s sslmode=%s",
+			cfg.DataBase.Host,
+			cfg.DataBase.User,
+			cfg.DataBase.Port,
+			cfg.DataBase.Password,
+			cfg.DataBase.Dbname,
+			cfg.DataBase.Sslmode,
+		),
+	}
 }
 
 func setupLogger(env string) *slog.Logger {
@@ -142,11 +428,11 @@ func setupLogger(env string) *slog.Logger {
 
 	switch env {
 	case envLocal:
-		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		log = slog.New(tracing.NewLogHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	case envDev:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		log = slog.New(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	case envProd:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		log = slog.New(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
 	}
 	return log
 
@@ -0,0 +1,21 @@
+package cache
+
+import "github.com/srKazuya/ordersPET/internal/storage"
+
+// OrderCache is shared by the services that read orders and the ones that
+// write them, so writers can populate or invalidate entries directly.
+type OrderCache interface {
+	Get(orderUID string) (storage.Order, bool)
+	Set(order storage.Order)
+	Delete(orderUID string)
+	// Generation and SetIfCurrent cache an order read from storage without
+	// undoing a Delete that happened during the read.
+	Generation(orderUID string) uint64
+	SetIfCurrent(order storage.Order, gen uint64) bool
+	// Generations and Fill add orders during warm-up without evicting
+	// anything or undoing a Delete; Fill reports false once the cache is full.
+	Generations() Generations
+	Fill(order storage.Order, gen uint64) bool
+}
+
+var _ OrderCache = (*LRU)(nil)
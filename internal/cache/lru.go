@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type Config struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+}
+
+// generations is the number of invalidation counters; keys share them by
+// hash, so an unrelated Delete occasionally skips a cache fill.
+const generations = 256
+
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// LRU is an order cache bounded by entry count and approximate size. Entries
+// older than the TTL are treated as missing; when a limit is exceeded the
+// least recently used entries are evicted. Zero limits disable the bound.
+type LRU struct {
+	cfg Config
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+	// gens counts Deletes per key hash, see Generation.
+	gens [generations]uint64
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// Generations is a snapshot of the invalidation generations, see
+// LRU.Generations.
+type Generations [generations]uint64
+
+// Of returns the generation of orderUID at the time of the snapshot.
+func (g *Generations) Of(orderUID string) uint64 {
+	return g[generation(orderUID)]
+}
+
+type entry struct {
+	order     storage.Order
+	size      int64
+	expiresAt time.Time
+}
+
+func New(cfg Config) *LRU {
+	return &LRU{
+		cfg:   cfg,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(orderUID string) (storage.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[orderUID]
+	if !ok {
+		c.misses.Add(1)
+		return storage.Order{}, false
+	}
+
+	e := el.Value.(*entry)
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		c.remove(el)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return storage.Order{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return clone(e.order), true
+}
+
+func (c *LRU) Set(order storage.Order) {
+	e, ok := c.newEntry(order)
+	if !ok {
+		c.Delete(order.OrderUID)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(order.OrderUID, e)
+}
+
+// Generation returns the invalidation generation of orderUID. Take it before
+// reading the order from storage and pass it to SetIfCurrent.
+func (c *LRU) Generation(orderUID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gens[generation(orderUID)]
+}
+
+// SetIfCurrent stores order like Set unless the key was deleted after gen
+// was taken, so a read that raced an invalidation cannot bring the stale
+// order back. It reports whether the order was stored.
+func (c *LRU) SetIfCurrent(order storage.Order, gen uint64) bool {
+	e, ok := c.newEntry(order)
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gens[generation(order.OrderUID)] != gen {
+		return false
+	}
+	c.set(order.OrderUID, e)
+	return true
+}
+
+// Generations returns every invalidation generation at once, for reads that
+// do not know in advance which orders they will return.
+func (c *LRU) Generations() Generations {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gens
+}
+
+// Fill adds order behind every other entry, as the least recently used one,
+// if it fits without evicting anything. Orders already cached, and orders
+// deleted after gen was taken, are left alone. Filling newest first
+// therefore keeps the newest orders when there is more to load than fits;
+// Fill reports false once the cache is full.
+func (c *LRU) Fill(order storage.Order, gen uint64) bool {
+	e, ok := c.newEntry(order)
+	if !ok {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[order.OrderUID]; ok || c.gens[generation(order.OrderUID)] != gen {
+		return true
+	}
+	if (c.cfg.MaxEntries > 0 && c.ll.Len() >= c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes+e.size > c.cfg.MaxBytes) {
+		return false
+	}
+
+	c.items[order.OrderUID] = c.ll.PushBack(e)
+	c.bytes += e.size
+	return true
+}
+
+func (c *LRU) Delete(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gens[generation(orderUID)]++
+	if el, ok := c.items[orderUID]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes := c.ll.Len(), c.bytes
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+		Entries:     entries,
+		Bytes:       bytes,
+	}
+}
+
+// newEntry wraps order for storage; ok is false when it is larger than the
+// whole cache.
+func (c *LRU) newEntry(order storage.Order) (*entry, bool) {
+	size := approxSize(&order)
+	if c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes {
+		return nil, false
+	}
+
+	e := &entry{order: clone(order), size: size}
+	if c.cfg.TTL > 0 {
+		e.expiresAt = time.Now().Add(c.cfg.TTL)
+	}
+	return e, true
+}
+
+func (c *LRU) set(orderUID string, e *entry) {
+	if el, ok := c.items[orderUID]; ok {
+		c.bytes += e.size - el.Value.(*entry).size
+		el.Value = e
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[orderUID] = c.ll.PushFront(e)
+		c.bytes += e.size
+	}
+
+	c.evict()
+}
+
+func (c *LRU) evict() {
+	for c.ll.Len() > 0 &&
+		((c.cfg.MaxEntries > 0 && c.ll.Len() > c.cfg.MaxEntries) ||
+			(c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes)) {
+		c.remove(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, e.order.OrderUID)
+	c.bytes -= e.size
+}
+
+func generation(orderUID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(orderUID))
+	return int(h.Sum32() % generations)
+}
+
+func clone(order storage.Order) storage.Order {
+	order.Items = append([]storage.Item(nil), order.Items...)
+	return order
+}
+
+// approxSize estimates the memory held by an order: string payloads plus
+// fixed-size fields and per-entry bookkeeping.
+func approxSize(o *storage.Order) int64 {
+	const (
+		orderFixed = 256
+		itemFixed  = 96
+	)
+
+	n := orderFixed + len(o.OrderUID) + len(o.TrackNumber) + len(o.Entry) + len(o.Locale) +
+		len(o.InternalSignature) + len(o.CustomerID) + len(o.DeliveryService) + len(o.ShardKey) +
+		len(o.OofShard) + len(o.Status)
+
+	d := o.Delivery
+	n += len(d.Name) + len(d.Phone) + len(d.Zip) + len(d.City) + len(d.Address) + len(d.Region) + len(d.Email)
+
+	p := o.Payment
+	n += len(p.Transaction) + len(p.RequestID) + len(p.Currency) + len(p.Provider) + len(p.Bank)
+
+	for _, it := range o.Items {
+		n += itemFixed + len(it.TrackNumber) + len(it.RID) + len(it.Name) + len(it.Size) + len(it.Brand)
+	}
+
+	return int64(n)
+}
@@ -0,0 +1,149 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/cache"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+func order(uid string) storage.Order {
+	return storage.Order{OrderUID: uid, TrackNumber: "WBILMTESTTRACK"}
+}
+
+func assertCached(t *testing.T, c *cache.LRU, uids ...string) {
+	t.Helper()
+
+	for _, uid := range uids {
+		if _, ok := c.Get(uid); !ok {
+			t.Errorf("Get(%q) missed, want a hit", uid)
+		}
+	}
+}
+
+func assertMissing(t *testing.T, c *cache.LRU, uids ...string) {
+	t.Helper()
+
+	for _, uid := range uids {
+		if _, ok := c.Get(uid); ok {
+			t.Errorf("Get(%q) hit, want a miss", uid)
+		}
+	}
+}
+
+func TestEvictByEntries(t *testing.T) {
+	c := cache.New(cache.Config{MaxEntries: 2})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Get("a") // b is now the least recently used
+	c.Set(order("c"))
+
+	assertMissing(t, c, "b")
+	assertCached(t, c, "a", "c")
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, want 1 eviction and 2 entries", stats)
+	}
+}
+
+func TestEvictByBytes(t *testing.T) {
+	probe := cache.New(cache.Config{})
+	probe.Set(order("a"))
+	size := probe.Stats().Bytes
+
+	c := cache.New(cache.Config{MaxBytes: 2*size + size/2})
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+
+	assertMissing(t, c, "a")
+	assertCached(t, c, "b", "c")
+	if stats := c.Stats(); stats.Bytes > 2*size+size/2 {
+		t.Errorf("Stats().Bytes = %d, want at most %d", stats.Bytes, 2*size+size/2)
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	c := cache.New(cache.Config{TTL: 10 * time.Millisecond})
+
+	c.Set(order("a"))
+	time.Sleep(20 * time.Millisecond)
+
+	assertMissing(t, c, "a")
+	stats := c.Stats()
+	if stats.Misses != 1 || stats.Expirations != 1 || stats.Hits != 0 || stats.Entries != 0 {
+		t.Errorf("Stats() = %+v, want 1 miss, 1 expiration and no entries", stats)
+	}
+}
+
+func TestSetOversized(t *testing.T) {
+	c := cache.New(cache.Config{MaxBytes: 1024})
+
+	c.Set(order("a"))
+	assertCached(t, c, "a")
+
+	big := order("a")
+	big.InternalSignature = strings.Repeat("x", 2048)
+	c.Set(big)
+
+	// The stale copy must not survive an update that does not fit.
+	assertMissing(t, c, "a")
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Stats() = %+v, want an empty cache", stats)
+	}
+}
+
+func TestFillKeepsFirstEntries(t *testing.T) {
+	c := cache.New(cache.Config{MaxEntries: 2})
+	gens := c.Generations()
+
+	for _, uid := range []string{"a", "b"} {
+		if !c.Fill(order(uid), gens.Of(uid)) {
+			t.Fatalf("Fill(%q) = false, want true", uid)
+		}
+	}
+	if c.Fill(order("c"), gens.Of("c")) {
+		t.Error("Fill into a full cache = true, want false")
+	}
+
+	assertCached(t, c, "a", "b")
+	assertMissing(t, c, "c")
+	if stats := c.Stats(); stats.Evictions != 0 {
+		t.Errorf("Stats().Evictions = %d, want 0", stats.Evictions)
+	}
+}
+
+func TestFillAfterDelete(t *testing.T) {
+	c := cache.New(cache.Config{})
+	gens := c.Generations()
+
+	c.Delete("a")
+
+	if !c.Fill(order("a"), gens.Of("a")) {
+		t.Error("Fill = false, want true: the cache is not full")
+	}
+	assertMissing(t, c, "a")
+}
+
+func TestSetIfCurrent(t *testing.T) {
+	c := cache.New(cache.Config{})
+
+	gen := c.Generation("a")
+	if !c.SetIfCurrent(order("a"), gen) {
+		t.Fatal("SetIfCurrent with the current generation = false, want true")
+	}
+	assertCached(t, c, "a")
+
+	gen = c.Generation("a")
+	c.Delete("a")
+	if c.SetIfCurrent(order("a"), gen) {
+		t.Error("SetIfCurrent after Delete = true, want false")
+	}
+	assertMissing(t, c, "a")
+
+	if !c.SetIfCurrent(order("a"), c.Generation("a")) {
+		t.Error("SetIfCurrent with a fresh generation = false, want true")
+	}
+}
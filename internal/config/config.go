@@ -9,16 +9,34 @@ import (
 )
 
 type Config struct {
-	Env        string `yaml:"env" env-defaut:"dev"`
-	HTTPServer `yaml:"http_server"`
-	DataBase   `yaml:"database"`
-	Kafka      `yaml:"kafka"`
+	Env             string        `yaml:"env" env-defaut:"dev"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"30s"`
+	HTTPServer      `yaml:"http_server"`
+	Storage         `yaml:"storage"`
+	DataBase        `yaml:"database"`
+	Kafka           `yaml:"kafka"`
+	Cache           `yaml:"cache"`
+	Tracing         `yaml:"tracing"`
+	Outbox          `yaml:"outbox"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env-defaut:"0.0.0.0:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"5s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// BatchMaxOrders caps the number of orders in one POST /orders/batch.
+	BatchMaxOrders int `yaml:"batch_max_orders" env-default:"500"`
+	// BatchMaxBytes caps the body of one POST /orders/batch.
+	BatchMaxBytes int64 `yaml:"batch_max_bytes" env-default:"16777216"`
+	// BatchTimeout bounds publishing one POST /orders/batch; in async and
+	// outbox modes orders are published one after another.
+	BatchTimeout time.Duration `yaml:"batch_timeout" env-default:"30s"`
+}
+
+type Storage struct {
+	// Driver is postgres or memory. The memory driver needs no database
+	// but keeps nothing across restarts and cannot be used with the outbox.
+	Driver string `yaml:"driver" env-default:"postgres"`
 }
 
 type DataBase struct {
@@ -33,8 +51,62 @@ type DataBase struct {
 type Kafka struct {
 	Brokers       []string `yaml:"brokers"`
 	Topic         string   `yaml:"topic"`
+	DLQTopic      string   `yaml:"dlq_topic" env-default:"orders-topic-dlq"`
 	GroupID       string   `yaml:"group_id"`
 	ConsumerGroup string   `yaml:"consumerGroup"`
+	Retry         Retry    `yaml:"retry"`
+	// PartitionKey is the order field messages are keyed by: order_uid,
+	// customer_id or shardkey.
+	PartitionKey   string   `yaml:"partition_key" env-default:"order_uid"`
+	PartitionQueue int      `yaml:"partition_queue" env-default:"100"`
+	Producer       Producer `yaml:"producer"`
+}
+
+type Producer struct {
+	// Mode is sync (wait for the broker ack) or async (queue and answer 202).
+	Mode      string        `yaml:"mode" env-default:"sync"`
+	QueueSize int           `yaml:"queue_size" env-default:"10000"`
+	Linger    time.Duration `yaml:"linger" env-default:"5ms"`
+}
+
+type Retry struct {
+	InitialInterval time.Duration `yaml:"initial_interval" env-default:"500ms"`
+	MaxInterval     time.Duration `yaml:"max_interval" env-default:"30s"`
+	Multiplier      float64       `yaml:"multiplier" env-default:"2"`
+	MaxAttempts     int           `yaml:"max_attempts" env-default:"0"`
+}
+
+type Cache struct {
+	MaxEntries int           `yaml:"max_entries" env-default:"10000"`
+	MaxBytes   int64         `yaml:"max_bytes" env-default:"67108864"`
+	TTL        time.Duration `yaml:"ttl" env-default:"10m"`
+	Warmup     Warmup        `yaml:"warmup"`
+}
+
+type Warmup struct {
+	Enabled   bool          `yaml:"enabled" env-default:"false"`
+	Limit     int           `yaml:"limit" env-default:"1000"`
+	Window    time.Duration `yaml:"window" env-default:"0s"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+	Timeout   time.Duration `yaml:"timeout" env-default:"1m"`
+}
+
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env-default:"otlp"`
+	Endpoint    string  `yaml:"endpoint" env-default:"localhost:4318"`
+	Insecure    bool    `yaml:"insecure" env-default:"true"`
+	FilePath    string  `yaml:"file_path" env-default:"traces.json"`
+	ServiceName string  `yaml:"service_name" env-default:"orders"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// Outbox, when enabled, makes POST /save store orders in Postgres and answer
+// 202; a relay publishes them to Kafka.
+type Outbox struct {
+	Enabled   bool          `yaml:"enabled" env-default:"false"`
+	Interval  time.Duration `yaml:"interval" env-default:"500ms"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+	Retention time.Duration `yaml:"retention" env-default:"24h"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,295 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/kafka"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+	orderPublisher "github.com/srKazuya/ordersPET/internal/service/publisher"
+	"github.com/srKazuya/ordersPET/internal/storage"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+// Per-item statuses.
+const (
+	StatusAccepted = "accepted"
+	StatusQueued   = "queued"
+	StatusInvalid  = "invalid"
+	StatusFailed   = "failed"
+)
+
+var (
+	ErrEmptyBatch    = errors.New("batch is empty")
+	ErrTooManyOrders = errors.New("too many orders in batch")
+)
+
+type Item struct {
+	Index        int               `json:"index"`
+	OrderUID     string            `json:"order_uid,omitempty"`
+	Status       string            `json:"status"`
+	AcceptanceID string            `json:"acceptance_id,omitempty"`
+	Errors       map[string]string `json:"errors,omitempty"`
+}
+
+type Response struct {
+	resp.ValidationResponse
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+	Results  []Item `json:"results"`
+}
+
+type OrdersPublisher interface {
+	PublishOrders(ctx context.Context, orders []storage.Order) []orderPublisher.Result
+}
+
+// entry is one decoded element of the batch; err is set when the element is
+// not a valid order document.
+type entry struct {
+	order storage.Order
+	err   error
+}
+
+// New accepts up to maxOrders orders in at most maxBytes as a JSON array or
+// as NDJSON. Every order is validated on its own and only the valid ones are
+// published, so a batch can partially succeed; the outcome of each order is
+// in Results. Publishing may take up to timeout, and the response then still
+// gets writeTimeout, whatever the server write timeout is.
+func New(log *slog.Logger, publisher OrdersPublisher, maxOrders int, maxBytes int64, timeout, writeTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.order.Batch"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		// The limit also bounds a single huge line or array element, which
+		// is buffered whole before the order count is checked.
+		entries, err := decode(http.MaxBytesReader(w, r.Body, maxBytes), maxOrders)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.ErrorContext(r.Context(), "batch body is too large", slog.Int64("max_bytes", maxBytes))
+			problem.Render(w, r, problem.TooLarge(problem.CodeBodyTooLarge,
+				fmt.Sprintf("batch body may be at most %d bytes", maxBytes)))
+			return
+		}
+		if errors.Is(err, ErrEmptyBatch) {
+			log.ErrorContext(r.Context(), "request BODY is empty")
+			problem.Render(w, r, problem.BadRequest(problem.CodeEmptyBody, "empty batch"))
+			return
+		}
+		if errors.Is(err, ErrTooManyOrders) {
+			log.ErrorContext(r.Context(), "batch is too large", slog.Int("max", maxOrders))
+			problem.Render(w, r, problem.TooLarge(problem.CodeTooManyItems,
+				fmt.Sprintf("batch may contain at most %d orders", maxOrders)))
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidJSON, "failed to decode request body"))
+			return
+		}
+
+		acceptLanguage := r.Header.Get("Accept-Language")
+		results := make([]Item, len(entries))
+		var (
+			orders  []storage.Order
+			indexes []int
+		)
+		for i, e := range entries {
+			results[i] = Item{Index: i, OrderUID: e.order.OrderUID}
+
+			if e.err != nil {
+				results[i].Status = StatusInvalid
+				results[i].Errors = decodeErrors(e.err)
+				continue
+			}
+
+			lang := resp.DetectLang(acceptLanguage, e.order.Locale)
+			if errs, err := resp.ValidateOrder(e.order, lang); err != nil {
+				log.ErrorContext(r.Context(), "failed to validate order", slog.Int("index", i), sl.Err(err))
+				results[i].Status = StatusFailed
+				results[i].Errors = map[string]string{"error": "failed to validate order"}
+				continue
+			} else if errs != nil {
+				results[i].Status = StatusInvalid
+				results[i].Errors = errs
+				continue
+			}
+
+			orders = append(orders, e.order)
+			indexes = append(indexes, i)
+		}
+
+		if len(orders) > 0 {
+			rc := http.NewResponseController(w)
+			if err := rc.SetWriteDeadline(time.Now().Add(timeout + writeTimeout)); err != nil {
+				log.WarnContext(r.Context(), "failed to extend write deadline", sl.Err(err))
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			for j, res := range publisher.PublishOrders(ctx, orders) {
+				item := &results[indexes[j]]
+				item.AcceptanceID = res.EventID
+				switch {
+				case res.Err != nil:
+					log.ErrorContext(r.Context(), "failed to produce order",
+						slog.String("order_uid", item.OrderUID), sl.Err(res.Err))
+					item.Status = StatusFailed
+					item.Errors = map[string]string{"error": publishError(res.Err)}
+				case res.Queued:
+					item.Status = StatusQueued
+				default:
+					item.Status = StatusAccepted
+				}
+			}
+		}
+
+		response := Response{ValidationResponse: resp.OK(), Results: results}
+		for _, item := range results {
+			if item.Status == StatusAccepted || item.Status == StatusQueued {
+				response.Accepted++
+			} else {
+				response.Rejected++
+			}
+		}
+
+		log.InfoContext(r.Context(), "batch processed",
+			slog.Int("orders", len(results)),
+			slog.Int("accepted", response.Accepted),
+			slog.Int("rejected", response.Rejected),
+		)
+		render.JSON(w, r, response)
+	}
+}
+
+func decodeErrors(err error) map[string]string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return map[string]string{typeErr.Field: "invalid type, expected " + typeErr.Type.String()}
+	}
+	return map[string]string{"error": "invalid JSON"}
+}
+
+func publishError(err error) string {
+	switch {
+	case errors.Is(err, kafka.ErrUnavailable):
+		return "kafka is unavailable"
+	case errors.Is(err, storage.ErrUnavailable):
+		return "storage is unavailable"
+	default:
+		return "failed to produce order"
+	}
+}
+
+// decode reads either a JSON array of orders or NDJSON, told apart by the
+// first non-blank byte. A bad NDJSON line only invalidates that order; a
+// broken array cannot be resynchronised and fails the whole request.
+func decode(body io.Reader, limit int) ([]entry, error) {
+	br := bufio.NewReader(body)
+
+	first, err := firstByte(br)
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyBatch
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	if first == '[' {
+		entries, err = decodeArray(br, limit)
+	} else {
+		entries, err = decodeLines(br, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	return entries, nil
+}
+
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+func decodeArray(r io.Reader, limit int) ([]entry, error) {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for dec.More() {
+		if len(entries) == limit {
+			return nil, ErrTooManyOrders
+		}
+
+		var e entry
+		if err := dec.Decode(&e.order); err != nil {
+			// A type mismatch still consumes the element, anything else
+			// leaves the decoder in an unknown position.
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return nil, err
+			}
+			e.err = err
+		}
+		entries = append(entries, e)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func decodeLines(br *bufio.Reader, limit int) ([]entry, error) {
+	var entries []entry
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(entries) == limit {
+				return nil, ErrTooManyOrders
+			}
+
+			var e entry
+			e.err = json.Unmarshal(line, &e.order)
+			entries = append(entries, e)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+	}
+}
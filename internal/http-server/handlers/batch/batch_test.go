@@ -0,0 +1,146 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		limit int
+		// want holds the order_uid of every entry, or "!" for an entry
+		// that failed to decode.
+		want    []string
+		wantErr error
+		// wantAnyErr is set when decoding must fail with some error.
+		wantAnyErr bool
+	}{
+		{name: "empty body", body: "", limit: 10, wantErr: ErrEmptyBatch},
+		{name: "blank body", body: " \n\t\r\n", limit: 10, wantErr: ErrEmptyBatch},
+		{name: "empty array", body: " []", limit: 10, wantErr: ErrEmptyBatch},
+		{name: "blank lines", body: "\n\n  \n", limit: 10, wantErr: ErrEmptyBatch},
+		{
+			name:  "array",
+			body:  `[{"order_uid":"a"}, {"order_uid":"b"}]`,
+			limit: 10,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "array after whitespace",
+			body:  "\n  [{\"order_uid\":\"a\"}]",
+			limit: 10,
+			want:  []string{"a"},
+		},
+		{
+			name:  "array resynchronises after a type error",
+			body:  `[{"order_uid":"a"}, {"order_uid":"b","items":"none"}, {"order_uid":"c"}]`,
+			limit: 10,
+			want:  []string{"a", "!", "c"},
+		},
+		{
+			name:       "broken array",
+			body:       `[{"order_uid":"a"}, {"order_uid":}]`,
+			limit:      10,
+			wantAnyErr: true,
+		},
+		{
+			name:       "unterminated array",
+			body:       `[{"order_uid":"a"}`,
+			limit:      10,
+			wantAnyErr: true,
+		},
+		{
+			name:  "ndjson",
+			body:  "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n",
+			limit: 10,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "ndjson without trailing newline and with blank lines",
+			body:  "{\"order_uid\":\"a\"}\r\n\n  \n{\"order_uid\":\"b\"}",
+			limit: 10,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "ndjson bad line only fails itself",
+			body:  "{\"order_uid\":\"a\"}\n{\"order_uid\":\n{\"order_uid\":\"c\",\"items\":1}\n{\"order_uid\":\"d\"}\n",
+			limit: 10,
+			want:  []string{"a", "!", "!", "d"},
+		},
+		{
+			name:  "array at the limit",
+			body:  `[{"order_uid":"a"}, {"order_uid":"b"}]`,
+			limit: 2,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:    "array over the limit",
+			body:    `[{"order_uid":"a"}, {"order_uid":"b"}, {"order_uid":"c"}]`,
+			limit:   2,
+			wantErr: ErrTooManyOrders,
+		},
+		{
+			name:  "ndjson at the limit",
+			body:  "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n\n",
+			limit: 2,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:    "ndjson over the limit",
+			body:    "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n{\"order_uid\":\"c\"}",
+			limit:   2,
+			wantErr: ErrTooManyOrders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := decode(strings.NewReader(tt.body), tt.limit)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("decode() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatalf("decode() = %d entries, want an error", len(entries))
+				}
+				return
+			case err != nil:
+				t.Fatalf("decode() error = %v", err)
+			}
+
+			got := make([]string, len(entries))
+			for i, e := range entries {
+				got[i] = e.order.OrderUID
+				if e.err != nil {
+					got[i] = "!"
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	entries, err := decode(strings.NewReader(`[{"order_uid":"a","items":"none"}]`), 10)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(entries[0].err, &typeErr) {
+		t.Fatalf("entry error = %v, want a type error", entries[0].err)
+	}
+	if got := decodeErrors(entries[0].err); got["items"] == "" {
+		t.Errorf("decodeErrors() = %v, want an error for items", got)
+	}
+}
@@ -0,0 +1,29 @@
+package cachestats
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/cache"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+type StatsProvider interface {
+	Stats() cache.Stats
+}
+
+type Response struct {
+	resp.ValidationResponse
+	Cache cache.Stats `json:"cache"`
+}
+
+func New(provider StatsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{
+			ValidationResponse: resp.OK(),
+			Cache:              provider.Stats(),
+		})
+	}
+}
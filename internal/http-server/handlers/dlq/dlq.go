@@ -0,0 +1,205 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/kafka"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 200
+	readTimeout  = 15 * time.Second
+)
+
+type DeadLetters interface {
+	List(ctx context.Context, partition int32, offset int64, limit int) ([]kafka.DeadMessage, error)
+	Get(ctx context.Context, partition int32, offset int64) (kafka.DeadMessage, error)
+	Redrive(ctx context.Context, partition int32, offset int64) error
+}
+
+type ListResponse struct {
+	resp.ValidationResponse
+	Messages []kafka.DeadMessage `json:"messages"`
+}
+
+type MessageResponse struct {
+	resp.ValidationResponse
+	Message kafka.DeadMessage `json:"message"`
+}
+
+func NewList(log *slog.Logger, dlq DeadLetters) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.dlq.List"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		q := r.URL.Query()
+		partition := int32(-1)
+		if v := q.Get("partition"); v != "" {
+			p, err := parsePartition(v)
+			if err != nil {
+				badRequest(w, r, log, "invalid partition", err)
+				return
+			}
+			partition = p
+		}
+		offset, err := queryInt(q.Get("offset"), 0)
+		if err != nil {
+			badRequest(w, r, log, "invalid offset", err)
+			return
+		}
+		limit, err := queryInt(q.Get("limit"), defaultLimit)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			badRequest(w, r, log, fmt.Sprintf("limit must be between 1 and %d", maxLimit), err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readTimeout)
+		defer cancel()
+
+		msgs, err := dlq.List(ctx, partition, offset, int(limit))
+		if err != nil {
+			failed(w, r, log, "failed to list dead-letter messages", err)
+			return
+		}
+
+		render.JSON(w, r, ListResponse{
+			ValidationResponse: resp.OK(),
+			Messages:           msgs,
+		})
+	}
+}
+
+func NewGet(log *slog.Logger, dlq DeadLetters) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.dlq.Get"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		partition, offset, err := position(r)
+		if err != nil {
+			badRequest(w, r, log, "invalid message position", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readTimeout)
+		defer cancel()
+
+		msg, err := dlq.Get(ctx, partition, offset)
+		if errors.Is(err, kafka.ErrDLQMessageMissing) {
+			log.Error("dead-letter message not found", sl.Err(err))
+			problem.Render(w, r, problem.NotFound(problem.CodeMessageNotFound, "dead-letter message not found"))
+			return
+		}
+		if err != nil {
+			failed(w, r, log, "failed to get dead-letter message", err)
+			return
+		}
+
+		render.JSON(w, r, MessageResponse{
+			ValidationResponse: resp.OK(),
+			Message:            msg,
+		})
+	}
+}
+
+func NewRedrive(log *slog.Logger, dlq DeadLetters) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.dlq.Redrive"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		partition, offset, err := position(r)
+		if err != nil {
+			badRequest(w, r, log, "invalid message position", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readTimeout)
+		defer cancel()
+
+		err = dlq.Redrive(ctx, partition, offset)
+		if errors.Is(err, kafka.ErrDLQMessageMissing) {
+			log.Error("dead-letter message not found", sl.Err(err))
+			problem.Render(w, r, problem.NotFound(problem.CodeMessageNotFound, "dead-letter message not found"))
+			return
+		}
+		if err != nil {
+			failed(w, r, log, "failed to redrive dead-letter message", err)
+			return
+		}
+
+		log.Info("dead-letter message redriven",
+			slog.Int("partition", int(partition)),
+			slog.Int64("offset", offset),
+		)
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func position(r *http.Request) (int32, int64, error) {
+	partition, err := parsePartition(chi.URLParam(r, "partition"))
+	if err != nil {
+		return 0, 0, err
+	}
+	offset, err := strconv.ParseInt(chi.URLParam(r, "offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset %q", chi.URLParam(r, "offset"))
+	}
+	return partition, offset, nil
+}
+
+// parsePartition accepts partition numbers that fit Kafka's int32 and are
+// not negative.
+func parsePartition(v string) (int32, error) {
+	partition, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || partition < 0 {
+		return 0, fmt.Errorf("invalid partition %q", v)
+	}
+	return int32(partition), nil
+}
+
+func queryInt(v string, def int64) (int64, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+func badRequest(w http.ResponseWriter, r *http.Request, log *slog.Logger, msg string, err error) {
+	if err != nil {
+		log.Error(msg, sl.Err(err))
+	} else {
+		log.Error(msg)
+	}
+	problem.Render(w, r, problem.BadRequest(problem.CodeInvalidParameter, msg))
+}
+
+func failed(w http.ResponseWriter, r *http.Request, log *slog.Logger, msg string, err error) {
+	log.Error(msg, sl.Err(err))
+	if errors.Is(err, kafka.ErrDLQRead) || errors.Is(err, kafka.ErrUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		problem.Render(w, r, problem.Unavailable("kafka is unavailable"))
+		return
+	}
+	problem.Render(w, r, problem.Internal(msg))
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+// A CSV row is one item with its order, delivery and payment columns
+// repeated. Column names are the json names of storage.Order, nested ones
+// prefixed with delivery_, payment_ and item_.
+var csvHeader = func() []string {
+	var header []string
+	header = append(header, scalarNames("", reflect.TypeOf(storage.Order{}))...)
+	header = append(header, scalarNames("delivery_", reflect.TypeOf(storage.Delivery{}))...)
+	header = append(header, scalarNames("payment_", reflect.TypeOf(storage.Payment{}))...)
+	header = append(header, scalarNames("item_", reflect.TypeOf(storage.Item{}))...)
+	return header
+}()
+
+func csvRecords(order storage.Order) [][]string {
+	head := scalarValues(reflect.ValueOf(order))
+	head = append(head, scalarValues(reflect.ValueOf(order.Delivery))...)
+	head = append(head, scalarValues(reflect.ValueOf(order.Payment))...)
+
+	records := make([][]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		record := make([]string, 0, len(csvHeader))
+		record = append(record, head...)
+		record = append(record, scalarValues(reflect.ValueOf(item))...)
+		records = append(records, record)
+	}
+	return records
+}
+
+// scalarNames returns the json names of the fields of t that are neither
+// nested structs nor slices.
+func scalarNames(prefix string, t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !scalar(f.Type) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		names = append(names, prefix+name)
+	}
+	return names
+}
+
+func scalarValues(v reflect.Value) []string {
+	var result []string
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if !scalar(f.Type()) {
+			continue
+		}
+		result = append(result, format(f))
+	}
+	return result
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func scalar(t reflect.Type) bool {
+	return t == timeType || (t.Kind() != reflect.Struct && t.Kind() != reflect.Slice)
+}
+
+func format(v reflect.Value) string {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).UTC().Format(time.RFC3339Nano)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	return ""
+}
@@ -0,0 +1,240 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/srKazuya/ordersPET/internal/http-server/handlers/list"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// flushEvery is the number of orders written between flushes to the client.
+const flushEvery = 100
+
+type OrderExporter interface {
+	ExportOrders(ctx context.Context, filter storage.OrderFilter, fn func(storage.Order) error) error
+}
+
+// encoder writes orders to a buffered response body.
+type encoder interface {
+	Encode(order storage.Order) error
+	Flush() error
+}
+
+// New streams every order matching the list filters as NDJSON (default) or
+// CSV, chosen by ?format= or the URL extension (/orders/export.csv). Orders
+// are written as they are read. Instead of the server write timeout, every
+// write to the client must complete within writeTimeout, so a long export
+// runs as long as the client keeps reading, however slowly orders are read
+// from storage. The export stops when the client goes away or stop is
+// closed, which the server does on shutdown.
+func New(log *slog.Logger, exporter OrderExporter, writeTimeout time.Duration, stop <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.order.Export"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		filter, err := list.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Error("invalid query", sl.Err(err))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidParameter, err.Error()))
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format, _ = r.Context().Value(middleware.URLFormatCtxKey).(string)
+		}
+		if format == "" {
+			format = FormatNDJSON
+		}
+		if format != FormatNDJSON && format != FormatCSV {
+			log.Error("unknown export format", slog.String("format", format))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidParameter,
+				fmt.Sprintf("invalid format %q: expected %s or %s", format, FormatNDJSON, FormatCSV)))
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		rc := http.NewResponseController(w)
+		extend := func() {
+			var deadline time.Time
+			if writeTimeout > 0 {
+				deadline = time.Now().Add(writeTimeout)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil {
+				log.Warn("failed to extend write deadline", sl.Err(err))
+			}
+		}
+		extend()
+
+		var (
+			enc   encoder
+			count int
+		)
+		// The response starts with the first order, so errors before it can
+		// still be reported as a problem.
+		start := func() {
+			enc = newEncoder(w, &deadlineWriter{w: w, extend: extend}, format)
+			w.WriteHeader(http.StatusOK)
+		}
+
+		err = exporter.ExportOrders(ctx, filter, func(order storage.Order) error {
+			if enc == nil {
+				start()
+			}
+			if err := enc.Encode(order); err != nil {
+				return err
+			}
+
+			count++
+			if count%flushEvery == 0 {
+				if err := enc.Flush(); err != nil {
+					return err
+				}
+				extend()
+				if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+					return err
+				}
+			}
+			return nil
+		})
+
+		switch {
+		case err != nil && enc != nil:
+			// Headers are sent, all we can do is cut the stream short.
+			log.Error("export aborted", slog.Int("orders", count), sl.Err(err))
+			return
+		case errors.Is(err, context.Canceled) && r.Context().Err() == nil:
+			log.Info("export cancelled by shutdown")
+			problem.Render(w, r, problem.Unavailable("server is shutting down"))
+			return
+		case errors.Is(err, context.Canceled):
+			log.Info("export cancelled by client")
+			return
+		case errors.Is(err, storage.ErrUnavailable):
+			log.Error("storage is unavailable", sl.Err(err))
+			problem.Render(w, r, problem.Unavailable("storage is unavailable"))
+			return
+		case err != nil:
+			log.Error("failed to export orders", sl.Err(err))
+			problem.Render(w, r, problem.Internal("failed to export orders"))
+			return
+		}
+
+		if enc == nil {
+			start()
+		}
+		if err := enc.Flush(); err != nil {
+			log.Error("failed to write export", sl.Err(err))
+			return
+		}
+
+		log.Info("orders exported", slog.Int("orders", count), slog.String("format", format))
+	}
+}
+
+// deadlineWriter extends the write deadline before every write to the
+// response, so the time spent reading orders between writes never counts.
+type deadlineWriter struct {
+	w      io.Writer
+	extend func()
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	d.extend()
+	return d.w.Write(p)
+}
+
+// newEncoder sets the response headers on w and writes the body to body.
+func newEncoder(w http.ResponseWriter, body io.Writer, format string) encoder {
+	bw := bufio.NewWriter(body)
+
+	if format == FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="orders.csv"`)
+		return newCSVEncoder(bw)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.ndjson"`)
+	return &ndjsonEncoder{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+type ndjsonEncoder struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(order storage.Order) error {
+	return e.enc.Encode(order)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.bw.Flush()
+}
+
+type csvEncoder struct {
+	bw     *bufio.Writer
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(bw *bufio.Writer) *csvEncoder {
+	return &csvEncoder{bw: bw, w: csv.NewWriter(bw)}
+}
+
+func (e *csvEncoder) Encode(order storage.Order) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.WriteAll(csvRecords(order))
+}
+
+// Flush also writes the header, so an empty export is still a valid CSV.
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return e.bw.Flush()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(csvHeader)
+}
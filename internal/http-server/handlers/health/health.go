@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	checkTimeout = 2 * time.Second
+)
+
+var ErrNotReady = errors.New("not ready yet")
+
+type Check struct {
+	Name     string
+	Required bool
+	Probe    func(ctx context.Context) error
+}
+
+type Component struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Response struct {
+	Status     string      `json:"status"`
+	Components []Component `json:"components,omitempty"`
+}
+
+// Flag adapts a readiness flag, such as "cache warmed up", to a probe.
+func Flag(ready func() bool) func(context.Context) error {
+	return func(context.Context) error {
+		if !ready() {
+			return ErrNotReady
+		}
+		return nil
+	}
+}
+
+func NewLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{Status: StatusUp})
+	}
+}
+
+// NewReadiness probes every dependency concurrently and answers 503 when any
+// required one is down.
+func NewReadiness(log *slog.Logger, checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.Readiness"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		components := make([]Component, len(checks))
+
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				components[i] = probe(ctx, check)
+			}()
+		}
+		wg.Wait()
+
+		result := Response{Status: StatusUp, Components: components}
+		for _, c := range components {
+			if c.Status == StatusDown && c.Required {
+				result.Status = StatusDown
+				log.Warn("dependency is down", slog.String("name", c.Name), slog.String("error", c.Error))
+			}
+		}
+
+		if result.Status == StatusDown {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+		render.JSON(w, r, result)
+	}
+}
+
+func probe(ctx context.Context, check Check) Component {
+	c := Component{
+		Name:     check.Name,
+		Status:   StatusUp,
+		Required: check.Required,
+	}
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	c.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = err.Error()
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+	"github.com/srKazuya/ordersPET/internal/storage"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+type OrderLister interface {
+	ListOrders(ctx context.Context, params storage.ListParams) (storage.OrdersPage, error)
+}
+
+type Response struct {
+	resp.ValidationResponse
+	Orders     []storage.Order `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func New(log *slog.Logger, lister OrderLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.order.List"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		params, err := ParseParams(r.URL.Query())
+		if err != nil {
+			log.Error("invalid query", sl.Err(err))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidParameter, err.Error()))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		page, err := lister.ListOrders(ctx, params)
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			log.Error("invalid cursor", sl.Err(err))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidParameter, "invalid cursor"))
+			return
+		}
+		if errors.Is(err, storage.ErrUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+			log.Error("storage is unavailable", sl.Err(err))
+			problem.Render(w, r, problem.Unavailable("storage is unavailable"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list orders", sl.Err(err))
+			problem.Render(w, r, problem.Internal("failed to list orders"))
+			return
+		}
+
+		log.Info("orders listed", slog.Int("count", len(page.Orders)))
+		render.JSON(w, r, Response{
+			ValidationResponse: resp.OK(),
+			Orders:             page.Orders,
+			NextCursor:         page.NextCursor,
+		})
+	}
+}
+
+func ParseParams(q url.Values) (storage.ListParams, error) {
+	filter, err := ParseFilter(q)
+	if err != nil {
+		return storage.ListParams{}, err
+	}
+
+	params := storage.ListParams{
+		Filter: filter,
+		Cursor: q.Get("cursor"),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return storage.ListParams{}, fmt.Errorf("invalid limit %q", v)
+		}
+		if limit > storage.MaxListLimit {
+			return storage.ListParams{}, fmt.Errorf("limit must not exceed %d", storage.MaxListLimit)
+		}
+		params.Limit = limit
+	}
+
+	return params, nil
+}
+
+func ParseFilter(q url.Values) (storage.OrderFilter, error) {
+	filter := storage.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		Currency:        q.Get("currency"),
+		Provider:        q.Get("provider"),
+		Brand:           q.Get("brand"),
+	}
+
+	var err error
+	if filter.DateFrom, err = parseTime(q, "date_from"); err != nil {
+		return storage.OrderFilter{}, err
+	}
+	if filter.DateTo, err = parseTime(q, "date_to"); err != nil {
+		return storage.OrderFilter{}, err
+	}
+
+	return filter, nil
+}
+
+func parseTime(q url.Values, key string) (time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, v)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected RFC 3339 or YYYY-MM-DD", key, v)
+	}
+
+	return t.UTC(), nil
+}
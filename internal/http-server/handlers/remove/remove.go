@@ -0,0 +1,64 @@
+package remove
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+	"github.com/srKazuya/ordersPET/internal/storage"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+type Response struct {
+	resp.ValidationResponse
+	OrderUID string `json:"order_uid"`
+}
+
+type OrderDeleter interface {
+	DeleteOrder(ctx context.Context, orderUID string) error
+}
+
+func New(log *slog.Logger, deleter OrderDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.order.Delete"
+
+		orderUID := chi.URLParam(r, "order_uid")
+		log := log.With(
+			slog.String("op", op),
+			slog.String("order_uid", orderUID),
+		)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		err := deleter.DeleteOrder(ctx, orderUID)
+		switch {
+		case errors.Is(err, storage.ErrOrderNotFound):
+			log.Error("order not found", sl.Err(err))
+			problem.Render(w, r, problem.NotFound(problem.CodeOrderNotFound, "order not found"))
+			return
+		case errors.Is(err, storage.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+			log.Error("storage is unavailable", sl.Err(err))
+			problem.Render(w, r, problem.Unavailable("storage is unavailable"))
+			return
+		case err != nil:
+			log.Error("failed to delete order", sl.Err(err))
+			problem.Render(w, r, problem.Internal("failed to delete order"))
+			return
+		}
+
+		log.Info("order deleted")
+		render.JSON(w, r, Response{
+			ValidationResponse: resp.OK(),
+			OrderUID:           orderUID,
+		})
+	}
+}
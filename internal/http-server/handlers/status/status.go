@@ -0,0 +1,104 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/problem"
+	"github.com/srKazuya/ordersPET/internal/storage"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+type Request struct {
+	Status string `json:"status"`
+}
+
+type Response struct {
+	resp.ValidationResponse
+	OrderUID       string              `json:"order_uid"`
+	PreviousStatus storage.OrderStatus `json:"previous_status"`
+	OrderStatus    storage.OrderStatus `json:"order_status"`
+	ChangedAt      time.Time           `json:"changed_at"`
+}
+
+type StatusUpdater interface {
+	UpdateOrderStatus(ctx context.Context, orderUID string, to storage.OrderStatus) (storage.StatusChange, error)
+}
+
+func New(log *slog.Logger, updater StatusUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.order.UpdateStatus"
+
+		orderUID := chi.URLParam(r, "order_uid")
+		log := log.With(
+			slog.String("op", op),
+			slog.String("order_uid", orderUID),
+		)
+
+		var req Request
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request BODY is empty")
+			problem.Render(w, r, problem.BadRequest(problem.CodeEmptyBody, "empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			problem.Render(w, r, problem.BadRequest(problem.CodeInvalidJSON, "failed to decode request body"))
+			return
+		}
+
+		to, err := storage.ParseOrderStatus(req.Status)
+		if err != nil {
+			log.Error("invalid status", sl.Err(err))
+			problem.Render(w, r, problem.Validation(map[string]string{"status": err.Error()}))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var transitionErr *storage.TransitionError
+
+		change, err := updater.UpdateOrderStatus(ctx, orderUID, to)
+		switch {
+		case errors.Is(err, storage.ErrOrderNotFound):
+			log.Error("order not found", sl.Err(err))
+			problem.Render(w, r, problem.NotFound(problem.CodeOrderNotFound, "order not found"))
+			return
+		case errors.As(err, &transitionErr):
+			log.Error("illegal status transition", sl.Err(err))
+			problem.Render(w, r, problem.New(http.StatusConflict, problem.CodeIllegalStatus, transitionErr.Error()))
+			return
+		case errors.Is(err, storage.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+			log.Error("storage is unavailable", sl.Err(err))
+			problem.Render(w, r, problem.Unavailable("storage is unavailable"))
+			return
+		case err != nil:
+			log.Error("failed to update order status", sl.Err(err))
+			problem.Render(w, r, problem.Internal("failed to update order status"))
+			return
+		}
+
+		log.Info("order status updated",
+			slog.String("from", string(change.From)),
+			slog.String("to", string(change.To)),
+		)
+		render.JSON(w, r, Response{
+			ValidationResponse: resp.OK(),
+			OrderUID:           change.OrderUID,
+			PreviousStatus:     change.From,
+			OrderStatus:        change.To,
+			ChangedAt:          change.ChangedAt,
+		})
+	}
+}
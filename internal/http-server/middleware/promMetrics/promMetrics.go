@@ -0,0 +1,39 @@
+package prommetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/srKazuya/ordersPET/internal/metrics"
+)
+
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			t1 := time.Now()
+			next.ServeHTTP(ww, r)
+
+			// The route pattern keeps label cardinality bounded, unlike the raw path.
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			code := strconv.Itoa(status)
+
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, code).Inc()
+			metrics.HTTPDuration.WithLabelValues(r.Method, route, code).Observe(time.Since(t1).Seconds())
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -1,39 +1,74 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/codes"
+
 	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/metrics"
 )
 
 var (
 	ErrCreateConsumer = errors.New("failed to create Kafka consumer")
 	ErrSubscribeTopic = errors.New("failed to subscribe on Kafka topic")
 	ErrReadMessage    = errors.New("failed to read Kafka message")
-	ErrSaveOrder      = errors.New("failed to save order")
+	ErrHandleEvent    = errors.New("failed to handle event")
 	ErrSaveOffset     = errors.New("failed to store offset")
 )
 
 const (
-	sessionTimeout = 7000
-	noTimeout      = -1
+	sessionTimeout        = 7000
+	rewindDelay           = time.Second
+	pollInterval          = 100 * time.Millisecond
+	defaultPartitionQueue = 100
 )
 
+// Consumer processes every assigned partition in its own worker goroutine.
+// Partitions run concurrently while messages within one partition, and so
+// all messages sharing a key, are handled strictly in offset order.
 type Consumer struct {
-	consumer *kafka.Consumer
-	Service  OrderSaver
-	stop     bool
+	consumer  *kafka.Consumer
+	log       *slog.Logger
+	topic     string
+	events    *Dispatcher
+	dlq       *DeadLetter
+	retry     RetryPolicy
+	queueSize int
+	stop      chan struct{}
+	stopOnce  sync.Once
+	started   atomic.Bool
+	done      chan struct{}
+
+	mu      sync.Mutex
+	workers map[int32]*partitionWorker
+}
+
+// NewConsumer subscribes to topic. queueSize bounds how many fetched messages
+// may wait for each partition worker; zero means defaultPartitionQueue.
+func NewConsumer(events *Dispatcher, log *slog.Logger, address []string, topic, consumerGroup string, dlq *DeadLetter, retry RetryPolicy, queueSize int) (*Consumer, error) {
+	return newConsumer(events, log, address, topic, consumerGroup, dlq, retry, queueSize, "earliest")
 }
 
-type OrderSaver interface {
-	SaveOrder(message []byte, offset *kafka.Offset) error
+// NewFanoutConsumer subscribes to topic in a group of its own, so every
+// process calling it with a distinct group sees every message, such as a
+// cache invalidation. It starts at the end of the topic, since older
+// messages are of no interest to a process that just started, and has no
+// dead-letter topic: handlers should ignore what they cannot process.
+func NewFanoutConsumer(events *Dispatcher, log *slog.Logger, address []string, topic, group string, retry RetryPolicy) (*Consumer, error) {
+	return newConsumer(events, log, address, topic, group, nil, retry, 0, "latest")
 }
 
-func NewConsumer(saver OrderSaver, log *slog.Logger, address []string, topic, consumerGroup string) (*Consumer, error) {
+func newConsumer(events *Dispatcher, log *slog.Logger, address []string, topic, consumerGroup string, dlq *DeadLetter, retry RetryPolicy, queueSize int, offsetReset string) (*Consumer, error) {
 	const op = "kafka.consumer"
 
 	log = log.With(
@@ -47,34 +82,61 @@ func NewConsumer(saver OrderSaver, log *slog.Logger, address []string, topic, co
 		"enable.auto.offset.store": false,
 		"enable.auto.commit":       true,
 		"auto.commit.interval.ms":  5000,
-		"auto.offset.reset":        "earliest",
+		"auto.offset.reset":        offsetReset,
 	}
 
-	c, err := kafka.NewConsumer(cfg)
+	kc, err := kafka.NewConsumer(cfg)
 	if err != nil {
 		log.Error("failed to create new consumer", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w: %v", op, ErrCreateConsumer, err)
 	}
 
-	if err = c.Subscribe(topic, nil); err != nil {
+	if queueSize <= 0 {
+		queueSize = defaultPartitionQueue
+	}
+
+	c := &Consumer{
+		consumer:  kc,
+		log:       log,
+		topic:     topic,
+		events:    events,
+		dlq:       dlq,
+		retry:     retry,
+		queueSize: queueSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		workers:   make(map[int32]*partitionWorker),
+	}
+
+	if err = kc.Subscribe(topic, c.rebalance); err != nil {
 		log.Error("failed to subscribe on topic", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w: topic=%s: %v", op, ErrSubscribeTopic, topic, err)
 	}
 
-	return &Consumer{
-		consumer: c,
-		Service:  saver,
-	}, nil
+	return c, nil
 }
 
+// Start polls messages and hands them to the worker of their partition. It
+// returns once Stop is called and every worker has finished its message in
+// progress.
 func (c *Consumer) Start(log *slog.Logger) {
+	c.started.Store(true)
+	defer close(c.done)
+	defer c.stopWorkers()
+
 	for {
-		if c.stop {
-			break
+		select {
+		case <-c.stop:
+			return
+		default:
 		}
 
-		kafkaMsg, err := c.consumer.ReadMessage(noTimeout)
+		kafkaMsg, err := c.consumer.ReadMessage(pollInterval)
 		if err != nil {
+			var kerr kafka.Error
+			if errors.As(err, &kerr) && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
 			log.Error("read message error", sl.Err(err))
 			err = fmt.Errorf("%w: %v", ErrReadMessage, err)
 			continue
@@ -84,24 +146,255 @@ func (c *Consumer) Start(log *slog.Logger) {
 			continue
 		}
 
-		if err := c.Service.SaveOrder(kafkaMsg.Value, &kafkaMsg.TopicPartition.Offset); err != nil {
-			log.Error("save order error", sl.Err(err))
-			err = fmt.Errorf("%w: %v", ErrSaveOrder, err)
-			continue
+		c.enqueue(log, kafkaMsg)
+	}
+}
+
+// enqueue passes msg to its partition worker. When the worker is too far
+// behind, the partition is paused and rewound to msg, which is fetched again
+// once the worker has caught up; the poll loop itself never blocks.
+func (c *Consumer) enqueue(log *slog.Logger, msg *kafka.Message) {
+	w := c.worker(log, msg.TopicPartition)
+	offset := msg.TopicPartition.Offset
+
+	if w.rewindTo >= 0 {
+		if offset != w.rewindTo {
+			return
+		}
+		w.rewindTo = -1
+	}
+
+	select {
+	case w.msgs <- msg:
+		return
+	default:
+	}
+
+	w.pause(c.consumer, log)
+	if err := c.consumer.Seek(msg.TopicPartition, 0); err != nil {
+		log.Error("failed to rewind partition", sl.Err(err), slog.Int("partition", int(w.partition)))
+		return
+	}
+	w.rewindTo = offset
+}
+
+// rebalance runs inside ReadMessage on the poll goroutine. Workers of revoked
+// partitions are stopped before their offsets are committed, so the next
+// owner starts right after the last processed message.
+func (c *Consumer) rebalance(_ *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		for _, tp := range e.Partitions {
+			c.worker(c.log, tp)
+		}
+		c.log.Info("partitions assigned", slog.Int("count", len(e.Partitions)))
+	case kafka.RevokedPartitions:
+		for _, tp := range e.Partitions {
+			c.stopWorker(tp.Partition)
+		}
+		if err := c.commit(); err != nil {
+			c.log.Error("failed to commit revoked partitions", sl.Err(err))
+		}
+		c.log.Info("partitions revoked", slog.Int("count", len(e.Partitions)))
+	}
+	return nil
+}
+
+func (c *Consumer) worker(log *slog.Logger, tp kafka.TopicPartition) *partitionWorker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if w, ok := c.workers[tp.Partition]; ok {
+		return w
+	}
+
+	w := newPartitionWorker(tp, c.queueSize)
+	c.workers[tp.Partition] = w
+	go c.run(log.With(slog.Int("partition", int(tp.Partition))), w)
+	return w
+}
+
+func (c *Consumer) stopWorker(partition int32) {
+	c.mu.Lock()
+	w, ok := c.workers[partition]
+	delete(c.workers, partition)
+	c.mu.Unlock()
+
+	if ok {
+		w.stop()
+	}
+}
+
+func (c *Consumer) stopWorkers() {
+	c.mu.Lock()
+	workers := c.workers
+	c.workers = make(map[int32]*partitionWorker)
+	c.mu.Unlock()
+
+	for _, w := range workers {
+		close(w.quit)
+	}
+	for _, w := range workers {
+		<-w.done
+	}
+}
+
+func (c *Consumer) run(log *slog.Logger, w *partitionWorker) {
+	defer close(w.done)
+
+	for {
+		// A stopped worker must not start on another queued message, and
+		// select alone picks among ready cases at random.
+		select {
+		case <-w.quit:
+			return
+		default:
 		}
 
-		if _, err = c.consumer.StoreMessage(kafkaMsg); err != nil {
-			log.Error("save offset error", sl.Err(err))
-			err = fmt.Errorf("%w: %v", ErrSaveOffset, err)
+		select {
+		case <-w.quit:
+			return
+		case msg := <-w.msgs:
+			ok := c.process(log, w, msg)
+			c.observeLag(msg)
+			if !ok {
+				return
+			}
+
+			if _, err := c.consumer.StoreMessage(msg); err != nil {
+				log.Error("save offset error", sl.Err(err))
+			}
+			w.resumeIfIdle(c.consumer, log)
+		}
+	}
+}
+
+// process handles the message, retrying transient failures in place with
+// backoff while the partition is paused. Messages that fail for good go to
+// the dead-letter topic; if that is impossible the message is retried after
+// rewindDelay so it is never skipped. It returns false only if the worker
+// was stopped first.
+func (c *Consumer) process(log *slog.Logger, w *partitionWorker, msg *kafka.Message) bool {
+	topic := topicName(msg.TopicPartition)
+
+	ctx, span := startProcessSpan(msg)
+	defer span.End()
+
+	for attempt := 1; ; attempt++ {
+		err := c.events.Dispatch(ctx, msg.Value)
+		if err == nil {
+			metrics.ConsumerMessages.WithLabelValues(topic, "processed").Inc()
+			return true
+		}
+
+		if !isTransient(err) || c.retry.Exhausted(attempt) {
+			log.ErrorContext(ctx, "handle event error", sl.Err(err), slog.Int("attempt", attempt))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			if c.deadLetter(ctx, log, msg, fmt.Errorf("%w: %v", ErrHandleEvent, err), attempt) {
+				metrics.ConsumerMessages.WithLabelValues(topic, "dead_lettered").Inc()
+				return true
+			}
+
+			metrics.ConsumerMessages.WithLabelValues(topic, "failed").Inc()
+			w.pause(c.consumer, log)
+			if !w.sleep(rewindDelay) {
+				return false
+			}
+			attempt = 0
 			continue
 		}
+
+		metrics.ConsumerRetries.WithLabelValues(topic).Inc()
+
+		delay := c.retry.Backoff(attempt)
+		log.WarnContext(ctx, "transient handle event error, retrying",
+			sl.Err(err),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", delay),
+		)
+
+		w.pause(c.consumer, log)
+		if !w.sleep(delay) {
+			return false
+		}
 	}
 }
 
-func (c *Consumer) Stop() error {
-	c.stop = true
+// observeLag uses the locally cached high watermark, so it costs no broker
+// round trip per message.
+func (c *Consumer) observeLag(msg *kafka.Message) {
+	topic := topicName(msg.TopicPartition)
+	partition := msg.TopicPartition.Partition
+
+	_, high, err := c.consumer.GetWatermarkOffsets(topic, partition)
+	if err != nil || high < 0 {
+		return
+	}
+
+	lag := high - int64(msg.TopicPartition.Offset) - 1
+	metrics.ConsumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(max(lag, 0)))
+}
+
+// deadLetter parks a failed message on the dead-letter topic and reports
+// whether that succeeded.
+func (c *Consumer) deadLetter(ctx context.Context, log *slog.Logger, msg *kafka.Message, cause error, attempts int) bool {
+	log = log.With(
+		slog.Int64("offset", int64(msg.TopicPartition.Offset)),
+	)
+
+	if c.dlq == nil {
+		log.ErrorContext(ctx, "dead-letter topic is not configured, retrying")
+		return false
+	}
+	if err := c.dlq.Send(ctx, msg, cause, attempts); err != nil {
+		log.ErrorContext(ctx, "failed to publish to dead-letter topic, retrying", sl.Err(err))
+		return false
+	}
+
+	log.WarnContext(ctx, "message moved to dead-letter topic", slog.String("dlq_topic", c.dlq.Topic()))
+	return true
+}
+
+// Ping fetches metadata for the subscribed topic.
+func (c *Consumer) Ping(ctx context.Context) error {
+	if _, err := c.consumer.GetMetadata(&c.topic, false, timeoutMs(ctx)); err != nil {
+		return fmt.Errorf("kafka.consumer.Ping: %w", err)
+	}
+	return nil
+}
+
+// Stop lets the messages in progress finish, commits stored offsets and
+// leaves the group. Queued messages are left for the next owner of their
+// partition. If ctx expires first the consumer is closed anyway and the
+// unfinished messages are redelivered.
+func (c *Consumer) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	if c.started.Load() {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return errors.Join(
+				fmt.Errorf("wait for message in progress: %w", ctx.Err()),
+				c.consumer.Close(),
+			)
+		}
+	}
+
+	var errs []error
+	if err := c.commit(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(append(errs, c.consumer.Close())...)
+}
+
+func (c *Consumer) commit() error {
 	if _, err := c.consumer.Commit(); err != nil {
-		return fmt.Errorf("commit error: %w", err)
+		var kerr kafka.Error
+		if !errors.As(err, &kerr) || kerr.Code() != kafka.ErrNoOffset {
+			return fmt.Errorf("commit error: %w", err)
+		}
 	}
-	return c.consumer.Close()
+	return nil
 }
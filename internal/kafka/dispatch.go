@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type HandlerFunc func(ctx context.Context, env Envelope) error
+
+// Upcaster migrates a payload from one schema version to the next.
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// Dispatcher routes decoded envelopes to the handler of their event type,
+// upcasting older payloads to the current schema version first.
+type Dispatcher struct {
+	handlers  map[EventType]HandlerFunc
+	upcasters map[EventType]map[int]Upcaster
+	fallback  HandlerFunc
+}
+
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		handlers:  make(map[EventType]HandlerFunc),
+		upcasters: make(map[EventType]map[int]Upcaster),
+	}
+
+	// Version 1 only wrapped the order into an envelope, the payload itself
+	// did not change.
+	d.Upcast(EventOrderCreated, legacyVersion, func(payload json.RawMessage) (json.RawMessage, error) {
+		return payload, nil
+	})
+
+	return d
+}
+
+func (d *Dispatcher) Handle(eventType EventType, h HandlerFunc) {
+	d.handlers[eventType] = h
+}
+
+// Default registers h for event types without a handler of their own. The
+// envelope is passed as received, without upcasting.
+func (d *Dispatcher) Default(h HandlerFunc) {
+	d.fallback = h
+}
+
+// Upcast registers u to migrate eventType payloads from version from to
+// from+1.
+func (d *Dispatcher) Upcast(eventType EventType, from int, u Upcaster) {
+	if d.upcasters[eventType] == nil {
+		d.upcasters[eventType] = make(map[int]Upcaster)
+	}
+	d.upcasters[eventType][from] = u
+}
+
+func (d *Dispatcher) Dispatch(ctx context.Context, value []byte) error {
+	env, err := DecodeEnvelope(value)
+	if err != nil {
+		return err
+	}
+
+	h, ok := d.handlers[env.EventType]
+	if !ok && d.fallback != nil {
+		return d.fallback(ctx, env)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownEvent, env.EventType)
+	}
+
+	if env, err = d.upcast(env); err != nil {
+		return err
+	}
+
+	return h(ctx, env)
+}
+
+func (d *Dispatcher) upcast(env Envelope) (Envelope, error) {
+	current, ok := SchemaVersions[env.EventType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %q", ErrUnknownEvent, env.EventType)
+	}
+	if env.SchemaVersion > current {
+		return Envelope{}, fmt.Errorf("%w: %s v%d is newer than v%d", ErrSchemaVersion, env.EventType, env.SchemaVersion, current)
+	}
+
+	for env.SchemaVersion < current {
+		u, ok := d.upcasters[env.EventType][env.SchemaVersion]
+		if !ok {
+			return Envelope{}, fmt.Errorf("%w: no upcaster for %s v%d", ErrSchemaVersion, env.EventType, env.SchemaVersion)
+		}
+
+		payload, err := u(env.Payload)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: upcast %s v%d: %v", ErrSchemaVersion, env.EventType, env.SchemaVersion, err)
+		}
+		env.Payload = payload
+		env.SchemaVersion++
+	}
+
+	return env, nil
+}
@@ -0,0 +1,313 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	HeaderDLQError             = "x-dlq-error"
+	HeaderDLQAttempts          = "x-dlq-attempts"
+	HeaderDLQOriginalTopic     = "x-dlq-original-topic"
+	HeaderDLQOriginalPartition = "x-dlq-original-partition"
+	HeaderDLQOriginalOffset    = "x-dlq-original-offset"
+	HeaderDLQOriginalTimestamp = "x-dlq-original-timestamp"
+	HeaderDLQFailedAt          = "x-dlq-failed-at"
+	HeaderDLQValidationErrors  = "x-dlq-validation-errors"
+
+	dlqHeaderPrefix = "x-dlq-"
+	metadataTimeout = 5000
+	readPollTimeout = 500 * time.Millisecond
+)
+
+var (
+	ErrDeadLetter        = errors.New("failed to publish message to dead-letter topic")
+	ErrDLQRead           = errors.New("failed to read dead-letter topic")
+	ErrDLQMessageMissing = errors.New("dead-letter message not found")
+)
+
+// fieldErrors is implemented by validation failures that carry per-field
+// messages.
+type fieldErrors interface {
+	FieldErrors() map[string]string
+}
+
+type DeadLetter struct {
+	producer  *Producer
+	address   []string
+	topic     string
+	mainTopic string
+}
+
+type DeadMessage struct {
+	Partition         int32             `json:"partition"`
+	Offset            int64             `json:"offset"`
+	Key               string            `json:"key,omitempty"`
+	Value             string            `json:"value"`
+	Error             string            `json:"error"`
+	Attempts          int               `json:"attempts"`
+	OriginalTopic     string            `json:"original_topic"`
+	OriginalPartition int32             `json:"original_partition"`
+	OriginalOffset    int64             `json:"original_offset"`
+	FailedAt          time.Time         `json:"failed_at"`
+	ValidationErrors  map[string]string `json:"validation_errors,omitempty"`
+	Headers           map[string]string `json:"headers,omitempty"`
+}
+
+func NewDeadLetter(producer *Producer, address []string, topic, mainTopic string) *DeadLetter {
+	return &DeadLetter{
+		producer:  producer,
+		address:   address,
+		topic:     topic,
+		mainTopic: mainTopic,
+	}
+}
+
+func (d *DeadLetter) Topic() string {
+	return d.topic
+}
+
+// Send copies msg to the dead-letter topic, keeping its key, value and
+// headers and describing the failure in x-dlq-* headers.
+func (d *DeadLetter) Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error {
+	const op = "kafka.DeadLetter.Send"
+
+	headers := withoutDLQHeaders(msg.Headers)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDLQOriginalTopic, Value: []byte(topicName(msg.TopicPartition))},
+		kafka.Header{Key: HeaderDLQOriginalPartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: HeaderDLQOriginalOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+		kafka.Header{Key: HeaderDLQOriginalTimestamp, Value: []byte(msg.Timestamp.UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	var fe fieldErrors
+	if errors.As(cause, &fe) {
+		if v, err := json.Marshal(fe.FieldErrors()); err == nil {
+			headers = append(headers, kafka.Header{Key: HeaderDLQValidationErrors, Value: v})
+		}
+	}
+
+	err := d.producer.ProduceMessage(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &d.topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w: %v", op, ErrDeadLetter, err)
+	}
+
+	return nil
+}
+
+// List reads up to limit dead-letter messages starting at offset. A negative
+// partition reads every partition of the topic.
+func (d *DeadLetter) List(ctx context.Context, partition int32, offset int64, limit int) ([]DeadMessage, error) {
+	const op = "kafka.DeadLetter.List"
+
+	msgs, err := d.read(ctx, partition, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := make([]DeadMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		result = append(result, toDeadMessage(msg))
+	}
+
+	return result, nil
+}
+
+func (d *DeadLetter) Get(ctx context.Context, partition int32, offset int64) (DeadMessage, error) {
+	const op = "kafka.DeadLetter.Get"
+
+	msg, err := d.readOne(ctx, partition, offset)
+	if err != nil {
+		return DeadMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return toDeadMessage(msg), nil
+}
+
+// Redrive publishes the dead-letter message back to the main topic with its
+// original key, value and headers.
+func (d *DeadLetter) Redrive(ctx context.Context, partition int32, offset int64) error {
+	const op = "kafka.DeadLetter.Redrive"
+
+	msg, err := d.readOne(ctx, partition, offset)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = d.producer.ProduceMessage(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &d.mainTopic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        withoutDLQHeaders(msg.Headers),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d *DeadLetter) readOne(ctx context.Context, partition int32, offset int64) (*kafka.Message, error) {
+	msgs, err := d.read(ctx, partition, offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || int64(msgs[0].TopicPartition.Offset) != offset {
+		return nil, fmt.Errorf("%w: partition=%d offset=%d", ErrDLQMessageMissing, partition, offset)
+	}
+	return msgs[0], nil
+}
+
+// read uses a throwaway consumer without a committed group position, so
+// inspecting the topic never moves anybody's offsets.
+func (d *DeadLetter) read(ctx context.Context, partition int32, offset int64, limit int) ([]*kafka.Message, error) {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  strings.Join(d.address, ","),
+		"group.id":           fmt.Sprintf("%s-inspector-%d", d.topic, time.Now().UnixNano()),
+		"enable.auto.commit": false,
+		"auto.offset.reset":  "earliest",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDLQRead, err)
+	}
+	defer c.Close()
+
+	partitions, err := d.partitions(c, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	start := kafka.Offset(offset)
+	if offset <= 0 {
+		start = kafka.OffsetBeginning
+	}
+
+	var assignment []kafka.TopicPartition
+	ends := make(map[int32]int64)
+	for _, p := range partitions {
+		_, high, err := c.QueryWatermarkOffsets(d.topic, p, metadataTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDLQRead, err)
+		}
+		if high <= offset {
+			continue
+		}
+		ends[p] = high
+		assignment = append(assignment, kafka.TopicPartition{Topic: &d.topic, Partition: p, Offset: start})
+	}
+	if len(assignment) == 0 {
+		return nil, nil
+	}
+
+	if err := c.Assign(assignment); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDLQRead, err)
+	}
+
+	var msgs []*kafka.Message
+	for len(ends) > 0 && len(msgs) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		msg, err := c.ReadMessage(readPollTimeout)
+		if err != nil {
+			var kerr kafka.Error
+			if errors.As(err, &kerr) && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %v", ErrDLQRead, err)
+		}
+
+		p := msg.TopicPartition.Partition
+		if int64(msg.TopicPartition.Offset) >= ends[p]-1 {
+			delete(ends, p)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+func (d *DeadLetter) partitions(c *kafka.Consumer, partition int32) ([]int32, error) {
+	if partition >= 0 {
+		return []int32{partition}, nil
+	}
+
+	md, err := c.GetMetadata(&d.topic, false, metadataTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDLQRead, err)
+	}
+
+	var result []int32
+	for _, p := range md.Topics[d.topic].Partitions {
+		result = append(result, p.ID)
+	}
+	return result, nil
+}
+
+func toDeadMessage(msg *kafka.Message) DeadMessage {
+	dm := DeadMessage{
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Key:       string(msg.Key),
+		Value:     string(msg.Value),
+		Headers:   make(map[string]string),
+	}
+
+	for _, h := range msg.Headers {
+		v := string(h.Value)
+		switch h.Key {
+		case HeaderDLQError:
+			dm.Error = v
+		case HeaderDLQAttempts:
+			dm.Attempts, _ = strconv.Atoi(v)
+		case HeaderDLQOriginalTopic:
+			dm.OriginalTopic = v
+		case HeaderDLQOriginalPartition:
+			p, _ := strconv.ParseInt(v, 10, 32)
+			dm.OriginalPartition = int32(p)
+		case HeaderDLQOriginalOffset:
+			dm.OriginalOffset, _ = strconv.ParseInt(v, 10, 64)
+		case HeaderDLQFailedAt:
+			dm.FailedAt, _ = time.Parse(time.RFC3339Nano, v)
+		case HeaderDLQValidationErrors:
+			_ = json.Unmarshal(h.Value, &dm.ValidationErrors)
+		default:
+			dm.Headers[h.Key] = v
+		}
+	}
+
+	return dm
+}
+
+func withoutDLQHeaders(headers []kafka.Header) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers))
+	for _, h := range headers {
+		if !strings.HasPrefix(h.Key, dlqHeaderPrefix) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func topicName(tp kafka.TopicPartition) string {
+	if tp.Topic == nil {
+		return ""
+	}
+	return *tp.Topic
+}
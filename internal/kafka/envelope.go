@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventOrderCreated       EventType = "OrderCreated"
+	EventOrderStatusChanged EventType = "OrderStatusChanged"
+	EventOrderDeleted       EventType = "OrderDeleted"
+)
+
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+
+	// legacyVersion is the schema version of messages published before the
+	// envelope existed: a bare order JSON without any metadata.
+	legacyVersion = 0
+)
+
+// SchemaVersions holds the current payload version of every event type.
+// Bumping one requires registering an upcaster from the previous version.
+var SchemaVersions = map[EventType]int{
+	EventOrderCreated:       1,
+	EventOrderStatusChanged: 1,
+	EventOrderDeleted:       1,
+}
+
+var (
+	ErrEnvelope      = errors.New("invalid event envelope")
+	ErrUnknownEvent  = errors.New("unknown event type")
+	ErrSchemaVersion = errors.New("unsupported schema version")
+)
+
+type Envelope struct {
+	EventID       string          `json:"event_id"`
+	EventType     EventType       `json:"event_type"`
+	SchemaVersion int             `json:"schema_version"`
+	ProducedAt    time.Time       `json:"produced_at"`
+	Source        string          `json:"source"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// StatusChangedPayload is the OrderStatusChanged payload.
+type StatusChangedPayload struct {
+	OrderUID  string    `json:"order_uid"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// OrderDeletedPayload is the OrderDeleted payload.
+type OrderDeletedPayload struct {
+	OrderUID  string    `json:"order_uid"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func NewEnvelope(eventType EventType, source string, payload any) (Envelope, error) {
+	version, ok := SchemaVersions[eventType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %q", ErrUnknownEvent, eventType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: marshal payload: %v", ErrEnvelope, err)
+	}
+
+	return Envelope{
+		EventID:       uuid.NewString(),
+		EventType:     eventType,
+		SchemaVersion: version,
+		ProducedAt:    time.Now().UTC(),
+		Source:        source,
+		Payload:       data,
+	}, nil
+}
+
+// DecodeEnvelope parses a message value. Values without an event_type are
+// treated as legacy OrderCreated messages carrying the order itself.
+func DecodeEnvelope(data []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrEnvelope, err)
+	}
+
+	if env.EventType == "" {
+		return Envelope{
+			EventType:     EventOrderCreated,
+			SchemaVersion: legacyVersion,
+			Payload:       bytes.Clone(data),
+		}, nil
+	}
+	if len(env.Payload) == 0 {
+		return Envelope{}, fmt.Errorf("%w: event %s has no payload", ErrEnvelope, env.EventID)
+	}
+
+	return env, nil
+}
@@ -1,73 +1,291 @@
 package kafka
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/metrics"
 )
 
 var (
 	ErrCreateProducer = errors.New("failed to create Kafka producer")
 	ErrUnknownType    = errors.New("unknown kafka error")
+	ErrFlush          = errors.New("failed to flush Kafka producer")
+	ErrUnavailable    = errors.New("kafka is unavailable")
+	ErrQueueFull      = errors.New("producer queue is full")
+)
+
+const (
+	flushTimeout     = 5000
+	defaultQueueSize = 10000
 )
 
-const flushTimeout = 5000
+type ProducerConfig struct {
+	// Source names this service as the origin of published events.
+	Source string
+	// QueueSize bounds the asynchronous messages awaiting a delivery report.
+	QueueSize int
+	// Linger lets librdkafka wait this long to batch messages together.
+	Linger time.Duration
+}
+
+// DeliveryFunc receives the outcome of an asynchronous send.
+type DeliveryFunc func(err error)
 
 type Producer struct {
 	producer *kafka.Producer
+	log      *slog.Logger
+	source   string
+	queue    chan struct{}
+	events   chan struct{}
 }
 
-func NewProducer(log *slog.Logger, address []string) (*Producer, error) {
+// pending travels with an asynchronous message as its Opaque value.
+type pending struct {
+	done  DeliveryFunc
+	span  trace.Span
+	start time.Time
+}
+
+func NewProducer(log *slog.Logger, address []string, cfg ProducerConfig) (*Producer, error) {
 	const op = "kafka.producer"
 
 	log = log.With(
 		slog.String("op", op),
 	)
 
-	cfg := &kafka.ConfigMap{
+	kcfg := &kafka.ConfigMap{
 		"bootstrap.servers": strings.Join(address, ","),
 	}
+	if cfg.Linger > 0 {
+		_ = kcfg.SetKey("linger.ms", int(cfg.Linger.Milliseconds()))
+	}
 
-	p, err := kafka.NewProducer(cfg)
+	kp, err := kafka.NewProducer(kcfg)
 	if err != nil {
 		log.Error("failed to create new producer", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w: %v", op, ErrCreateProducer, err)
 	}
 
-	return &Producer{producer: p}, nil
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+
+	p := &Producer{
+		producer: kp,
+		log:      log,
+		source:   cfg.Source,
+		queue:    make(chan struct{}, cfg.QueueSize),
+		events:   make(chan struct{}),
+	}
+	go p.handleEvents()
+
+	return p, nil
+}
+
+func (p *Producer) ProduceEvent(ctx context.Context, topic, key string, env Envelope) error {
+	msg, err := eventMessage(topic, key, env)
+	if err != nil {
+		return err
+	}
+	return p.ProduceMessage(ctx, msg)
 }
 
-func (p *Producer) Produce(message, topic string) error {
-	kafkaMsg := &kafka.Message{
+// ProduceEventAsync queues env and returns at once; done is called with the
+// delivery outcome. A full queue is reported as ErrUnavailable so callers can
+// shed load.
+func (p *Producer) ProduceEventAsync(ctx context.Context, topic, key string, env Envelope, done DeliveryFunc) error {
+	msg, err := eventMessage(topic, key, env)
+	if err != nil {
+		return err
+	}
+	return p.ProduceMessageAsync(ctx, msg, done)
+}
+
+func eventMessage(topic, key string, env Envelope) (*kafka.Message, error) {
+	value, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEnvelope, err)
+	}
+
+	return &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
-		Value: []byte(message),
-		Key:   nil,
+		Key:   []byte(key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderEventID, Value: []byte(env.EventID)},
+			{Key: HeaderEventType, Value: []byte(env.EventType)},
+			{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(env.SchemaVersion))},
+		},
+	}, nil
+}
+
+// ProduceMessage sends a prepared message and waits for its delivery report.
+func (p *Producer) ProduceMessage(ctx context.Context, kafkaMsg *kafka.Message) error {
+	topic := topicName(kafkaMsg.TopicPartition)
+	start := time.Now()
+
+	_, span := startProduceSpan(ctx, kafkaMsg)
+	defer span.End()
+
+	err := p.produce(ctx, kafkaMsg)
+
+	metrics.KafkaProduceDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.KafkaProduceErrors.WithLabelValues(topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
+	return err
+}
 
-	kafkaChan := make(chan kafka.Event)
+// ProduceMessageAsync hands the message to librdkafka, which batches it with
+// others. The delivery report arrives on the Events channel and is passed to
+// done from a single background goroutine, so done must not block.
+func (p *Producer) ProduceMessageAsync(ctx context.Context, kafkaMsg *kafka.Message, done DeliveryFunc) error {
+	topic := topicName(kafkaMsg.TopicPartition)
+
+	select {
+	case p.queue <- struct{}{}:
+	default:
+		metrics.KafkaProduceErrors.WithLabelValues(topic).Inc()
+		return fmt.Errorf("%w: %w", ErrUnavailable, ErrQueueFull)
+	}
+
+	_, span := startProduceSpan(ctx, kafkaMsg)
+	kafkaMsg.Opaque = &pending{done: done, span: span, start: time.Now()}
+
+	if err := p.producer.Produce(kafkaMsg, nil); err != nil {
+		<-p.queue
+		err = fmt.Errorf("produce error: %w", classify(err))
+		p.finish(topic, kafkaMsg.Opaque.(*pending), err)
+		return err
+	}
+	return nil
+}
+
+func (p *Producer) handleEvents() {
+	defer close(p.events)
+
+	for e := range p.producer.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			pd, ok := ev.Opaque.(*pending)
+			if !ok {
+				continue
+			}
+			<-p.queue
+
+			var err error
+			if ev.TopicPartition.Error != nil {
+				err = fmt.Errorf("delivery error: %w", classify(ev.TopicPartition.Error))
+			}
+			p.finish(topicName(ev.TopicPartition), pd, err)
+		case kafka.Error:
+			p.log.Warn("kafka producer error", sl.Err(ev))
+		}
+	}
+}
+
+func (p *Producer) finish(topic string, pd *pending, err error) {
+	metrics.KafkaProduceDuration.WithLabelValues(topic).Observe(time.Since(pd.start).Seconds())
+	if err != nil {
+		metrics.KafkaProduceErrors.WithLabelValues(topic).Inc()
+		pd.span.RecordError(err)
+		pd.span.SetStatus(codes.Error, err.Error())
+	}
+	pd.span.End()
+
+	if pd.done != nil {
+		pd.done(err)
+	}
+}
+
+// produce gives up waiting for the delivery report once ctx is done; the
+// message stays queued and may still be delivered later.
+func (p *Producer) produce(ctx context.Context, kafkaMsg *kafka.Message) error {
+	kafkaChan := make(chan kafka.Event, 1)
 	if err := p.producer.Produce(kafkaMsg, kafkaChan); err != nil {
-		return fmt.Errorf("produce error: %w", err)
+		return fmt.Errorf("produce error: %w", classify(err))
+	}
+
+	var e kafka.Event
+	select {
+	case e = <-kafkaChan:
+	case <-ctx.Done():
+		return fmt.Errorf("delivery report: %w: %w", ErrUnavailable, ctx.Err())
 	}
 
-	e := <-kafkaChan
 	switch ev := e.(type) {
 	case *kafka.Message:
+		if ev.TopicPartition.Error != nil {
+			return fmt.Errorf("delivery error: %w", classify(ev.TopicPartition.Error))
+		}
 		return nil
 	case kafka.Error:
-		return fmt.Errorf("kafka error: %w", ev)
+		return fmt.Errorf("kafka error: %w", classify(ev))
 	default:
 		return fmt.Errorf("%w: got %T", ErrUnknownType, ev)
 	}
 }
 
-func (p *Producer) Close() {
-	p.producer.Flush(flushTimeout)
+// classify marks errors caused by unreachable brokers or a full local queue
+// with ErrUnavailable.
+func classify(err error) error {
+	var kerr kafka.Error
+	if !errors.As(err, &kerr) {
+		return err
+	}
+
+	switch kerr.Code() {
+	case kafka.ErrTransport, kafka.ErrAllBrokersDown, kafka.ErrMsgTimedOut, kafka.ErrTimedOut,
+		kafka.ErrQueueFull, kafka.ErrNetworkException, kafka.ErrBrokerNotAvailable,
+		kafka.ErrLeaderNotAvailable, kafka.ErrNotLeaderForPartition:
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// Ping fetches cluster metadata to prove at least one broker is reachable.
+func (p *Producer) Ping(ctx context.Context) error {
+	if _, err := p.producer.GetMetadata(nil, false, timeoutMs(ctx)); err != nil {
+		return fmt.Errorf("kafka.producer.Ping: %w", err)
+	}
+	return nil
+}
+
+// Close waits for queued messages to be delivered, until ctx expires, and
+// reports how many were left undelivered.
+func (p *Producer) Close(ctx context.Context) error {
+	remaining := p.producer.Flush(timeoutMs(ctx))
 	p.producer.Close()
+	<-p.events
+
+	if remaining > 0 {
+		return fmt.Errorf("%w: %d messages not delivered", ErrFlush, remaining)
+	}
+	return nil
+}
+
+// timeoutMs converts the ctx deadline to the millisecond timeouts librdkafka
+// expects, falling back to flushTimeout.
+func timeoutMs(ctx context.Context) int {
+	if deadline, ok := ctx.Deadline(); ok {
+		return max(int(time.Until(deadline).Milliseconds()), 0)
+	}
+	return flushTimeout
 }
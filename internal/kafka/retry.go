@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// MaxAttempts bounds how many times a message is tried before it goes to
+	// the failure path. Zero retries transient errors until shutdown.
+	MaxAttempts int
+}
+
+// Backoff returns the delay before the given retry attempt (1-based): an
+// exponentially growing interval capped at MaxInterval, with equal jitter.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.InitialInterval <= 0 {
+		return 0
+	}
+
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+
+	d := float64(p.InitialInterval) * math.Pow(mult, float64(attempt-1))
+	if p.MaxInterval > 0 && d > float64(p.MaxInterval) {
+		d = float64(p.MaxInterval)
+	}
+
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+func (p RetryPolicy) Exhausted(attempt int) bool {
+	return p.MaxAttempts > 0 && attempt >= p.MaxAttempts
+}
+
+// isTransient reports whether err, or anything it wraps, declares itself
+// temporary. The saver marks database outages this way.
+func isTransient(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/srKazuya/ordersPET/internal/kafka")
+
+// headerCarrier lets the OpenTelemetry propagator read and write W3C trace
+// context in Kafka message headers.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+func startProduceSpan(ctx context.Context, msg *kafka.Message) (context.Context, trace.Span) {
+	topic := topicName(msg.TopicPartition)
+
+	ctx, span := tracer.Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation.type", "publish"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &msg.Headers})
+
+	return ctx, span
+}
+
+func startProcessSpan(msg *kafka.Message) (context.Context, trace.Span) {
+	topic := topicName(msg.TopicPartition)
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{headers: &msg.Headers})
+
+	return tracer.Start(ctx, topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.destination.partition.id", strconv.Itoa(int(msg.TopicPartition.Partition))),
+			attribute.Int64("messaging.kafka.offset", int64(msg.TopicPartition.Offset)),
+		),
+	)
+}
@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+)
+
+// partitionWorker owns the processing of one partition.
+type partitionWorker struct {
+	partition int32
+	tp        []kafka.TopicPartition
+	msgs      chan *kafka.Message
+	quit      chan struct{}
+	done      chan struct{}
+
+	// mu makes changing paused and pausing or resuming the partition one
+	// step; both the poll and the worker goroutine do it.
+	mu     sync.Mutex
+	paused bool
+
+	// rewindTo is the offset the partition was rewound to after the queue
+	// overflowed; messages before it comes back are stale. Only the poll
+	// goroutine touches it.
+	rewindTo kafka.Offset
+}
+
+func newPartitionWorker(tp kafka.TopicPartition, queueSize int) *partitionWorker {
+	topic := topicName(tp)
+	return &partitionWorker{
+		partition: tp.Partition,
+		tp:        []kafka.TopicPartition{{Topic: &topic, Partition: tp.Partition}},
+		msgs:      make(chan *kafka.Message, queueSize),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		rewindTo:  -1,
+	}
+}
+
+// stop abandons queued messages and waits for the one in progress.
+func (w *partitionWorker) stop() {
+	close(w.quit)
+	<-w.done
+}
+
+// sleep waits for d and returns false if the worker is stopped meanwhile.
+func (w *partitionWorker) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-w.quit:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// pause stops fetching the partition; the group still sees the consumer as
+// alive because the poll loop keeps running.
+func (w *partitionWorker) pause(c *kafka.Consumer, log *slog.Logger) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paused {
+		return
+	}
+	if err := c.Pause(w.tp); err != nil {
+		log.Error("failed to pause partition", sl.Err(err))
+		return
+	}
+	w.paused = true
+}
+
+// resumeIfIdle resumes a paused partition once the worker has drained its
+// queue.
+func (w *partitionWorker) resumeIfIdle(c *kafka.Consumer, log *slog.Logger) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.paused || len(w.msgs) > 0 {
+		return
+	}
+	if err := c.Resume(w.tp); err != nil {
+		log.Error("failed to resume partition", sl.Err(err))
+		return
+	}
+	w.paused = false
+}
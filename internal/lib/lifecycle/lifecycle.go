@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+)
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager stops registered components one after another, in the order they
+// were added, within a single shutdown deadline.
+type Manager struct {
+	log     *slog.Logger
+	timeout time.Duration
+	hooks   []hook
+}
+
+func New(log *slog.Logger, timeout time.Duration) *Manager {
+	return &Manager{
+		log:     log.With(slog.String("component", "lifecycle")),
+		timeout: timeout,
+	}
+}
+
+func (m *Manager) Add(name string, stop func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Shutdown runs every hook even after the deadline has passed, so that
+// resources which do not need the context still get released.
+func (m *Manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	m.log.Info("shutdown started", slog.Duration("timeout", m.timeout))
+
+	var errs []error
+	for _, h := range m.hooks {
+		t1 := time.Now()
+		if err := h.stop(ctx); err != nil {
+			m.log.Error("failed to stop component", slog.String("name", h.name), sl.Err(err))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		m.log.Info("component stopped",
+			slog.String("name", h.name),
+			slog.Duration("took", time.Since(t1)),
+		)
+	}
+
+	m.log.Info("shutdown finished", slog.Duration("took", time.Since(start)))
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// Stable machine-readable codes. Clients match on these, so never rename one.
+const (
+	CodeEmptyBody        = "empty_body"
+	CodeInvalidJSON      = "invalid_json"
+	CodeValidation       = "validation_failed"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeOrderNotFound    = "order_not_found"
+	CodeMessageNotFound  = "message_not_found"
+	CodeIllegalStatus    = "illegal_status_transition"
+	CodeTooManyItems     = "too_many_items"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeUnavailable      = "service_unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details body extended with a stable code and
+// per-field validation errors.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func Validation(errs map[string]string) Problem {
+	p := New(http.StatusUnprocessableEntity, CodeValidation, "request validation failed")
+	p.Errors = errs
+	return p
+}
+
+func BadRequest(code, detail string) Problem {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func TooLarge(code, detail string) Problem {
+	return New(http.StatusRequestEntityTooLarge, code, detail)
+}
+
+func NotFound(code, detail string) Problem {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Unavailable(detail string) Problem {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, detail)
+}
+
+func Internal(detail string) Problem {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+func Render(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(body)
+}
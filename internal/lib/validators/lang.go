@@ -0,0 +1,48 @@
+package validators
+
+import (
+	"golang.org/x/text/language"
+)
+
+type Lang string
+
+const (
+	LangRU Lang = "ru"
+	LangEN Lang = "en"
+
+	DefaultLang = LangRU
+)
+
+var (
+	supported = []Lang{LangRU, LangEN}
+	matcher   = language.NewMatcher([]language.Tag{language.Russian, language.English})
+)
+
+// DetectLang picks the message language from an Accept-Language header,
+// falling back to the order's locale and then to DefaultLang.
+func DetectLang(acceptLanguage, locale string) Lang {
+	if lang, ok := match(acceptLanguage); ok {
+		return lang
+	}
+	if lang, ok := match(locale); ok {
+		return lang
+	}
+	return DefaultLang
+}
+
+func match(s string) (Lang, bool) {
+	if s == "" {
+		return "", false
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(s)
+	if err != nil || len(tags) == 0 {
+		return "", false
+	}
+
+	_, idx, conf := matcher.Match(tags...)
+	if conf == language.No {
+		return "", false
+	}
+	return supported[idx], true
+}
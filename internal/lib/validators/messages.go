@@ -0,0 +1,83 @@
+package validators
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-playground/validator"
+)
+
+type message struct {
+	// plain is used for strings and numbers, elems for slices and maps.
+	plain string
+	elems string
+}
+
+var catalog = map[Lang]map[string]message{
+	LangRU: {
+		"required": {plain: "Это поле обязательно"},
+		"alpha":    {plain: "Допустимы только буквы"},
+		"alphanum": {plain: "Допустимы только буквы и цифры"},
+		"email":    {plain: "Некорректный адрес электронной почты"},
+		"e164":     {plain: "Телефон должен быть в формате E.164, например +79001234567"},
+		"len":      {plain: "Длина должна быть ровно %s", elems: "Должно быть ровно %s элементов"},
+		"min":      {plain: "Значение должно быть не меньше %s", elems: "Должно быть не меньше %s элементов"},
+		"max":      {plain: "Значение должно быть не больше %s", elems: "Должно быть не больше %s элементов"},
+		"gt":       {plain: "Значение должно быть больше %s", elems: "Должно быть больше %s элементов"},
+		"gte":      {plain: "Значение должно быть не меньше %s", elems: "Должно быть не меньше %s элементов"},
+		"lt":       {plain: "Значение должно быть меньше %s", elems: "Должно быть меньше %s элементов"},
+		"lte":      {plain: "Значение должно быть не больше %s", elems: "Должно быть не больше %s элементов"},
+	},
+	LangEN: {
+		"required": {plain: "This field is required"},
+		"alpha":    {plain: "Only letters are allowed"},
+		"alphanum": {plain: "Only letters and digits are allowed"},
+		"email":    {plain: "Must be a valid email address"},
+		"e164":     {plain: "Phone must be in E.164 format, e.g. +79001234567"},
+		"len":      {plain: "Length must be exactly %s", elems: "Must contain exactly %s items"},
+		"min":      {plain: "Must be at least %s", elems: "Must contain at least %s items"},
+		"max":      {plain: "Must be at most %s", elems: "Must contain at most %s items"},
+		"gt":       {plain: "Must be greater than %s", elems: "Must contain more than %s items"},
+		"gte":      {plain: "Must be at least %s", elems: "Must contain at least %s items"},
+		"lt":       {plain: "Must be less than %s", elems: "Must contain fewer than %s items"},
+		"lte":      {plain: "Must be at most %s", elems: "Must contain at most %s items"},
+	},
+}
+
+var fallback = map[Lang]string{
+	LangRU: "Значение не прошло проверку %s",
+	LangEN: "Failed the %s check",
+}
+
+// Messages renders every failure in lang, keyed by its JSON field path.
+func Messages(errs validator.ValidationErrors, lang Lang) map[string]string {
+	if _, ok := catalog[lang]; !ok {
+		lang = DefaultLang
+	}
+
+	result := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		result[FieldPath(fe)] = translate(fe, lang)
+	}
+	return result
+}
+
+func translate(fe validator.FieldError, lang Lang) string {
+	msg, ok := catalog[lang][fe.Tag()]
+	if !ok {
+		return fmt.Sprintf(fallback[lang], fe.Tag())
+	}
+
+	tmpl := msg.plain
+	if msg.elems != "" {
+		switch fe.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			tmpl = msg.elems
+		}
+	}
+
+	if fe.Param() == "" {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, fe.Param())
+}
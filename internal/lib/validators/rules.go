@@ -0,0 +1,124 @@
+package validators
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+const (
+	RuleGoodsTotal  = "goods_total"
+	RuleItemTotal   = "item_total"
+	RuleSale        = "sale"
+	RuleAmount      = "amount"
+	RuleTrackNumber = "track_number"
+)
+
+// RuleError is a business rule violated by one field of an order.
+type RuleError struct {
+	Field    string
+	Rule     string
+	Expected string
+}
+
+type RuleErrors []RuleError
+
+func (e RuleErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, re := range e {
+		parts[i] = fmt.Sprintf("%s: %s (expected %s)", re.Field, re.Rule, re.Expected)
+	}
+	return "order violates business rules: " + strings.Join(parts, "; ")
+}
+
+// Messages renders every violation in lang, keyed by its JSON field path, in
+// the same shape as tag validation failures.
+func (e RuleErrors) Messages(lang Lang) map[string]string {
+	if _, ok := ruleCatalog[lang]; !ok {
+		lang = DefaultLang
+	}
+
+	result := make(map[string]string, len(e))
+	for _, re := range e {
+		result[re.Field] = fmt.Sprintf(ruleCatalog[lang][re.Rule], re.Expected)
+	}
+	return result
+}
+
+var ruleCatalog = map[Lang]map[string]string{
+	LangRU: {
+		RuleGoodsTotal:  "Должно равняться сумме items[].total_price: %s",
+		RuleItemTotal:   "Должно равняться price с учётом скидки sale: %s",
+		RuleSale:        "Скидка должна быть меньше %s%%",
+		RuleAmount:      "Должно равняться goods_total + delivery_cost + custom_fee: %s",
+		RuleTrackNumber: "Должен совпадать с track_number заказа: %s",
+	},
+	LangEN: {
+		RuleGoodsTotal:  "Must equal the sum of items[].total_price: %s",
+		RuleItemTotal:   "Must equal price with the sale discount applied: %s",
+		RuleSale:        "Sale must be less than %s%%",
+		RuleAmount:      "Must equal goods_total + delivery_cost + custom_fee: %s",
+		RuleTrackNumber: "Must match the order track_number: %s",
+	},
+}
+
+// CheckOrder verifies that the totals of an order add up and that its items
+// belong to it. It assumes tag validation has already passed and returns nil
+// when the order is consistent.
+func CheckOrder(o storage.Order) RuleErrors {
+	var errs RuleErrors
+
+	goodsTotal := 0
+	for i, item := range o.Items {
+		goodsTotal += item.TotalPrice
+
+		if item.TrackNumber != o.TrackNumber {
+			errs = append(errs, RuleError{
+				Field:    fmt.Sprintf("items[%d].track_number", i),
+				Rule:     RuleTrackNumber,
+				Expected: o.TrackNumber,
+			})
+		}
+
+		// A full discount would need a zero total_price, which tag
+		// validation rejects, so it can never be satisfied.
+		if item.Sale >= 100 {
+			errs = append(errs, RuleError{
+				Field:    fmt.Sprintf("items[%d].sale", i),
+				Rule:     RuleSale,
+				Expected: "100",
+			})
+			continue
+		}
+
+		// Discounted prices are rounded to whole units either way.
+		discounted := item.Price * (100 - item.Sale)
+		if floor, ceil := discounted/100, (discounted+99)/100; item.TotalPrice != floor && item.TotalPrice != ceil {
+			errs = append(errs, RuleError{
+				Field:    fmt.Sprintf("items[%d].total_price", i),
+				Rule:     RuleItemTotal,
+				Expected: strconv.Itoa(floor),
+			})
+		}
+	}
+
+	p := o.Payment
+	if p.GoodsTotal != goodsTotal {
+		errs = append(errs, RuleError{
+			Field:    "payment.goods_total",
+			Rule:     RuleGoodsTotal,
+			Expected: strconv.Itoa(goodsTotal),
+		})
+	}
+	if amount := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != amount {
+		errs = append(errs, RuleError{
+			Field:    "payment.amount",
+			Rule:     RuleAmount,
+			Expected: strconv.Itoa(amount),
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,113 @@
+package validators_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/srKazuya/ordersPET/internal/lib/validators"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+// consistentOrder returns an order whose totals add up: 1000 at 30% off plus
+// 999 at 50% off, rounded up to 500.
+func consistentOrder() storage.Order {
+	return storage.Order{
+		TrackNumber: "WBILMTESTTRACK",
+		Payment: storage.Payment{
+			Amount:       1700,
+			DeliveryCost: 500,
+			GoodsTotal:   1200,
+			CustomFee:    0,
+		},
+		Items: []storage.Item{
+			{TrackNumber: "WBILMTESTTRACK", Price: 1000, Sale: 30, TotalPrice: 700},
+			{TrackNumber: "WBILMTESTTRACK", Price: 999, Sale: 50, TotalPrice: 500},
+		},
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *storage.Order)
+		want   validators.RuleErrors
+	}{
+		{
+			name:   "consistent",
+			modify: func(o *storage.Order) {},
+		},
+		{
+			name: "discount rounded down",
+			modify: func(o *storage.Order) {
+				o.Items[1].TotalPrice = 499
+				o.Payment.GoodsTotal, o.Payment.Amount = 1199, 1699
+			},
+		},
+		{
+			name: "discount rounded past ceil",
+			modify: func(o *storage.Order) {
+				o.Items[1].TotalPrice = 501
+				o.Payment.GoodsTotal, o.Payment.Amount = 1201, 1701
+			},
+			want: validators.RuleErrors{
+				{Field: "items[1].total_price", Rule: validators.RuleItemTotal, Expected: "499"},
+			},
+		},
+		{
+			name: "full sale",
+			modify: func(o *storage.Order) {
+				o.Items[0].Sale = 100
+			},
+			want: validators.RuleErrors{
+				{Field: "items[0].sale", Rule: validators.RuleSale, Expected: "100"},
+			},
+		},
+		{
+			name: "sale over 100",
+			modify: func(o *storage.Order) {
+				o.Items[0].Sale = 150
+			},
+			want: validators.RuleErrors{
+				{Field: "items[0].sale", Rule: validators.RuleSale, Expected: "100"},
+			},
+		},
+		{
+			name: "goods_total mismatch",
+			modify: func(o *storage.Order) {
+				o.Payment.GoodsTotal, o.Payment.Amount = 1000, 1500
+			},
+			want: validators.RuleErrors{
+				{Field: "payment.goods_total", Rule: validators.RuleGoodsTotal, Expected: "1200"},
+			},
+		},
+		{
+			name: "amount mismatch",
+			modify: func(o *storage.Order) {
+				o.Payment.CustomFee = 10
+			},
+			want: validators.RuleErrors{
+				{Field: "payment.amount", Rule: validators.RuleAmount, Expected: "1710"},
+			},
+		},
+		{
+			name: "item of another order",
+			modify: func(o *storage.Order) {
+				o.Items[1].TrackNumber = "OTHER"
+			},
+			want: validators.RuleErrors{
+				{Field: "items[1].track_number", Rule: validators.RuleTrackNumber, Expected: "WBILMTESTTRACK"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := consistentOrder()
+			tt.modify(&order)
+
+			if got := validators.CheckOrder(order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package validators
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+var validate = newValidate()
+
+// Struct validates s with the shared validator. Failures are reported as
+// validator.ValidationErrors whose Namespace uses JSON field names.
+func Struct(s any) error {
+	return validate.Struct(s)
+}
+
+// ValidateOrder applies the tag and business rules every accepted order must
+// pass. It returns field errors in lang for an invalid order, and an error
+// only when validation itself could not run.
+func ValidateOrder(order storage.Order, lang Lang) (map[string]string, error) {
+	if err := Struct(order); err != nil {
+		var validateErr validator.ValidationErrors
+		if !errors.As(err, &validateErr) {
+			return nil, err
+		}
+		return Messages(validateErr, lang), nil
+	}
+
+	if ruleErrs := CheckOrder(order); ruleErrs != nil {
+		return ruleErrs.Messages(lang), nil
+	}
+	return nil, nil
+}
+
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// FieldPath turns a namespace like "Request.items[2].price" into the JSON
+// path "items[2].price".
+func FieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/srKazuya/ordersPET/internal/cache"
+)
+
+const namespace = "orders"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	KafkaProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka_producer",
+		Name:      "produce_duration_seconds",
+		Help:      "Time from produce call to delivery report.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaProduceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka_producer",
+		Name:      "errors_total",
+		Help:      "Messages that failed to be produced.",
+	}, []string{"topic"})
+
+	ConsumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka_consumer",
+		Name:      "messages_total",
+		Help:      "Consumed messages by outcome: processed, dead_lettered or failed.",
+	}, []string{"topic", "result"})
+
+	ConsumerRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka_consumer",
+		Name:      "retries_total",
+		Help:      "Transient failures retried in place.",
+	}, []string{"topic"})
+
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka_consumer",
+		Name:      "lag",
+		Help:      "Messages between the last processed offset and the high watermark.",
+	}, []string{"topic", "partition"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Storage operation latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func RegisterCache(stats func() cache.Stats) {
+	counter := func(name, help string, value func(cache.Stats) uint64) prometheus.CounterFunc {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(value(stats())) })
+	}
+
+	prometheus.MustRegister(
+		counter("hits_total", "Order cache hits.", func(s cache.Stats) uint64 { return s.Hits }),
+		counter("misses_total", "Order cache misses.", func(s cache.Stats) uint64 { return s.Misses }),
+		counter("evictions_total", "Entries evicted by size limits.", func(s cache.Stats) uint64 { return s.Evictions }),
+		counter("expirations_total", "Entries dropped after their TTL.", func(s cache.Stats) uint64 { return s.Expirations }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "hit_ratio",
+			Help:      "Share of lookups served from the cache since start.",
+		}, func() float64 {
+			s := stats()
+			if s.Hits+s.Misses == 0 {
+				return 0
+			}
+			return float64(s.Hits) / float64(s.Hits+s.Misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "entries",
+			Help:      "Orders currently cached.",
+		}, func() float64 { return float64(stats().Entries) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "bytes",
+			Help:      "Approximate size of cached orders.",
+		}, func() float64 { return float64(stats().Bytes) }),
+	)
+}
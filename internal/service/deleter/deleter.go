@@ -0,0 +1,106 @@
+package orderDeleter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/cache"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	orderPublisher "github.com/srKazuya/ordersPET/internal/service/publisher"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type Deleter struct {
+	log       *slog.Logger
+	storage   OrderDeleter
+	outbox    OutboxDeleter
+	cache     cache.OrderCache
+	publisher EventPublisher
+}
+
+type OrderDeleter interface {
+	GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error)
+	DeleteOrder(ctx context.Context, orderUID string) error
+}
+
+// OutboxDeleter deletes an order and enqueues its event in one transaction.
+type OutboxDeleter interface {
+	DeleteOrderWithOutbox(ctx context.Context, orderUID string, announce func(storage.Order) (storage.OutboxMessage, error)) error
+}
+
+type EventPublisher interface {
+	OrderDeleted(order storage.Order, deletedAt time.Time) (orderPublisher.Event, error)
+	OutboxMessage(e orderPublisher.Event) (storage.OutboxMessage, error)
+	PublishEvent(ctx context.Context, e orderPublisher.Event) error
+}
+
+// New creates a Deleter. With a non-nil outbox deletions are announced
+// through it in the transaction that deletes the order; otherwise they are
+// published once committed, and on a failure other replicas keep their
+// cached copy until its TTL runs out.
+func New(log *slog.Logger, deleter OrderDeleter, outbox OutboxDeleter, cache cache.OrderCache, publisher EventPublisher) *Deleter {
+	return &Deleter{
+		log:       log,
+		storage:   deleter,
+		outbox:    outbox,
+		cache:     cache,
+		publisher: publisher,
+	}
+}
+
+// DeleteOrder removes the order, drops it from this replica's cache and
+// announces the deletion so every other replica drops it too.
+func (d *Deleter) DeleteOrder(ctx context.Context, orderUID string) error {
+	const op = "orderDeleter.DeleteOrder"
+
+	if d.outbox != nil {
+		if err := d.outbox.DeleteOrderWithOutbox(ctx, orderUID, d.outboxMessage); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		d.invalidate(orderUID)
+		return nil
+	}
+
+	// Read first: the event is keyed like the order's other events.
+	order, err := d.storage.GetOrderByUID(ctx, orderUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := d.storage.DeleteOrder(ctx, orderUID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	d.invalidate(orderUID)
+	d.announce(ctx, order)
+
+	return nil
+}
+
+func (d *Deleter) invalidate(orderUID string) {
+	d.cache.Delete(orderUID)
+	d.log.Info("order cache invalidated", slog.String("order_id", orderUID))
+}
+
+func (d *Deleter) outboxMessage(order storage.Order) (storage.OutboxMessage, error) {
+	e, err := d.publisher.OrderDeleted(order, time.Now().UTC())
+	if err != nil {
+		return storage.OutboxMessage{}, err
+	}
+	return d.publisher.OutboxMessage(e)
+}
+
+// announce publishes the deletion for other services and replicas. The order
+// is already deleted, so a failure is logged rather than returned.
+func (d *Deleter) announce(ctx context.Context, order storage.Order) {
+	e, err := d.publisher.OrderDeleted(order, time.Now().UTC())
+	if err == nil {
+		err = d.publisher.PublishEvent(ctx, e)
+	}
+	if err != nil {
+		d.log.ErrorContext(ctx, "failed to publish order deletion",
+			slog.String("order_id", order.OrderUID), sl.Err(err))
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/srKazuya/ordersPET/internal/cache"
 	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
 	"github.com/srKazuya/ordersPET/internal/storage"
 )
@@ -14,38 +15,33 @@ import (
 type Getter struct {
 	log     *slog.Logger
 	storage OrderGetter
-
-	cache struct {
-		sync.RWMutex
-		data map[string]*storage.Order
-	}
+	cache   cache.OrderCache
+	warmed  atomic.Bool
 }
 
 type OrderGetter interface {
 	GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error)
 }
 
-func New(log *slog.Logger, getter OrderGetter) *Getter {
-	g := &Getter{
+func New(log *slog.Logger, getter OrderGetter, cache cache.OrderCache) *Getter {
+	return &Getter{
 		log:     log,
 		storage: getter,
+		cache:   cache,
 	}
-	g.cache.data = make(map[string]*storage.Order)
-	return g
 }
 
 func (g *Getter) GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error) {
 	const op = "orderGetter.GetOrderByUID"
-	fmt.Println("GetOrderByUID вызван")
 
-	g.cache.RLock()
-	order, found := g.cache.data[orderUID]
-	g.cache.RUnlock()
-	if found {
+	if order, found := g.cache.Get(orderUID); found {
 		g.log.Info("order found in cache", slog.String("order_id", orderUID))
-		return *order, nil
+		return order, nil
 	}
 
+	// Taken before the read: a Delete racing it makes the result stale.
+	gen := g.cache.Generation(orderUID)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -57,11 +53,11 @@ func (g *Getter) GetOrderByUID(ctx context.Context, orderUID string) (storage.Or
 
 	g.log.Info("order retrieved from storage", slog.String("order_id", orderVal.OrderUID))
 
-	g.cache.Lock()
-	g.cache.data[orderVal.OrderUID] = &orderVal
-	g.cache.Unlock()
-
-	g.log.Info("order cached successfully")
+	if g.cache.SetIfCurrent(orderVal, gen) {
+		g.log.Info("order cached successfully")
+	} else {
+		g.log.Info("order changed while it was read, not cached", slog.String("order_id", orderVal.OrderUID))
+	}
 
 	return orderVal, nil
 }
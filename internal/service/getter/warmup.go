@@ -0,0 +1,93 @@
+package orderGetter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type OrderLister interface {
+	ListOrders(ctx context.Context, params storage.ListParams) (storage.OrdersPage, error)
+}
+
+type WarmupOptions struct {
+	// Limit caps the number of most recent orders loaded; zero means no cap.
+	Limit int
+	// Window restricts warm-up to orders created within it; zero means any age.
+	Window    time.Duration
+	BatchSize int
+}
+
+// Warm loads recent orders into the cache page by page, newest first, and
+// stops early once the cache is full so the newest orders are the ones kept.
+// Once it returns, successfully or not, Warmed reports true.
+func (g *Getter) Warm(ctx context.Context, lister OrderLister, opts WarmupOptions) (int, error) {
+	const op = "orderGetter.Warm"
+
+	defer g.warmed.Store(true)
+
+	log := g.log.With(slog.String("op", op))
+
+	if opts.Limit <= 0 && opts.Window <= 0 {
+		return 0, fmt.Errorf("%s: either limit or window must be set", op)
+	}
+
+	params := storage.ListParams{Limit: opts.BatchSize}
+	if opts.Window > 0 {
+		params.Filter.DateFrom = time.Now().Add(-opts.Window).UTC()
+	}
+
+	start := time.Now()
+	loaded := 0
+
+	for {
+		if opts.Limit > 0 {
+			params.Limit = min(params.PageLimit(), opts.Limit-loaded)
+		}
+
+		// Taken before the read, so an order changed or deleted while the
+		// page is loaded is not cached in its old state.
+		gens := g.cache.Generations()
+		page, err := lister.ListOrders(ctx, params)
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %w", op, err)
+		}
+
+		full := false
+		for _, order := range page.Orders {
+			if !g.cache.Fill(order, gens.Of(order.OrderUID)) {
+				full = true
+				break
+			}
+			loaded++
+		}
+
+		log.Info("cache warm-up progress",
+			slog.Int("loaded", loaded),
+			slog.Duration("elapsed", time.Since(start)),
+		)
+
+		if full {
+			log.Info("cache is full, stopping warm-up")
+			break
+		}
+		if page.NextCursor == "" || (opts.Limit > 0 && loaded >= opts.Limit) {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+
+	log.Info("cache warm-up finished",
+		slog.Int("loaded", loaded),
+		slog.Duration("took", time.Since(start)),
+	)
+
+	return loaded, nil
+}
+
+func (g *Getter) Warmed() bool {
+	return g.warmed.Load()
+}
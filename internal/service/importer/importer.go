@@ -0,0 +1,282 @@
+package orderImporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/storage"
+
+	resp "github.com/srKazuya/ordersPET/internal/lib/validators"
+)
+
+const DefaultBatchSize = 5000
+
+// Reasons a line is rejected.
+const (
+	ReasonInvalidJSON = "invalid_json"
+	ReasonValidation  = "validation_failed"
+	ReasonConflict    = "order_conflict"
+)
+
+var ErrFileChanged = errors.New("import file shrank since the last run")
+
+type Store interface {
+	GetImportProgress(ctx context.Context, file string) (storage.ImportProgress, error)
+	SaveImportProgress(ctx context.Context, p storage.ImportProgress) error
+	ImportOrders(ctx context.Context, p storage.ImportProgress, records []storage.ImportRecord) (storage.ImportProgress, []storage.ImportConflict, error)
+}
+
+type Config struct {
+	BatchSize int
+	// Restart ignores saved progress and reads files from the beginning.
+	Restart bool
+	// Final says files are complete, so a last line without a newline is
+	// imported. Otherwise it is left for a later run, since the writer may
+	// be partway through it.
+	Final bool
+	// Rejects, when set, receives a JSON line for every line that was not
+	// imported. Lines after the last committed batch are reported again
+	// when an interrupted import is resumed.
+	Rejects io.Writer
+}
+
+// Reject is an input line that was not imported.
+type Reject struct {
+	File     string            `json:"file"`
+	Line     int64             `json:"line"`
+	OrderUID string            `json:"order_uid,omitempty"`
+	Reason   string            `json:"reason"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+type Importer struct {
+	log     *slog.Logger
+	store   Store
+	cfg     Config
+	rejects *json.Encoder
+}
+
+func New(log *slog.Logger, store Store, cfg Config) *Importer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	im := &Importer{
+		log:   log,
+		store: store,
+		cfg:   cfg,
+	}
+	if cfg.Rejects != nil {
+		im.rejects = json.NewEncoder(cfg.Rejects)
+	}
+	return im
+}
+
+// ImportFile imports the NDJSON file at path, resuming after the last
+// committed batch of an earlier run. A file that grew since it was completed
+// is imported from where the previous run ended; unless Config.Final is set,
+// an unterminated last line is not counted as read until it is completed.
+func (im *Importer) ImportFile(ctx context.Context, path string) (storage.ImportProgress, error) {
+	const op = "orderImporter.ImportFile"
+
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return storage.ImportProgress{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log := im.log.With(
+		slog.String("op", op),
+		slog.String("file", file),
+	)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return storage.ImportProgress{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return storage.ImportProgress{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	progress := storage.ImportProgress{File: file}
+	if !im.cfg.Restart {
+		if progress, err = im.store.GetImportProgress(ctx, file); err != nil {
+			return storage.ImportProgress{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	switch {
+	case info.Size() < progress.Offset:
+		return progress, fmt.Errorf("%s: %w: %s", op, ErrFileChanged, file)
+	case progress.Completed() && info.Size() == progress.Size:
+		log.Info("file is already imported", slog.Int64("lines", progress.Line))
+		return progress, nil
+	case progress.Offset > 0:
+		log.Info("resuming import", slog.Int64("line", progress.Line), slog.Int64("offset", progress.Offset))
+	}
+	progress.Size = info.Size()
+	progress.CompletedAt = time.Time{}
+
+	if _, err := f.Seek(progress.Offset, io.SeekStart); err != nil {
+		return progress, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		br      = bufio.NewReaderSize(f, 1<<20)
+		records = make([]storage.ImportRecord, 0, im.cfg.BatchSize)
+		start   = time.Now()
+		first   = progress.Line
+		// pending is set when an unterminated last line is left unread.
+		pending bool
+	)
+
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+
+		next, conflicts, err := im.store.ImportOrders(ctx, progress, records)
+		if err != nil {
+			return err
+		}
+		for _, c := range conflicts {
+			im.reject(Reject{File: file, Line: c.Line, OrderUID: c.OrderUID, Reason: ReasonConflict,
+				Errors: map[string]string{c.Key: "is already used by a different order"}})
+		}
+		progress = next
+		records = records[:0]
+
+		im.report(log, progress, first, start)
+		return nil
+	}
+
+	for {
+		raw, readErr := br.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return progress, fmt.Errorf("%s: read: %w", op, readErr)
+		}
+		if len(raw) == 0 && errors.Is(readErr, io.EOF) {
+			break
+		}
+		if errors.Is(readErr, io.EOF) && !im.cfg.Final && len(bytes.TrimSpace(raw)) > 0 {
+			log.Warn("last line has no newline, leaving it for the next run",
+				slog.Int64("line", progress.Line+1))
+			pending = true
+			break
+		}
+
+		progress.Line++
+		progress.Offset += int64(len(raw))
+
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			var order storage.Order
+			if err := json.Unmarshal(raw, &order); err != nil {
+				progress.Invalid++
+				im.reject(Reject{File: file, Line: progress.Line, Reason: ReasonInvalidJSON,
+					Errors: map[string]string{"error": err.Error()}})
+			} else if errs, err := validate(order); err != nil {
+				return progress, fmt.Errorf("%s: line %d: %w", op, progress.Line, err)
+			} else if errs != nil {
+				progress.Invalid++
+				im.reject(Reject{File: file, Line: progress.Line, OrderUID: order.OrderUID,
+					Reason: ReasonValidation, Errors: errs})
+			} else {
+				records = append(records, storage.ImportRecord{Line: progress.Line, Order: order})
+			}
+		}
+
+		if len(records) == im.cfg.BatchSize {
+			if err := flush(); err != nil {
+				return progress, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		return progress, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Size covers only what was read, so a later run with the same file does
+	// not take it for completely imported.
+	if pending {
+		progress.Size = progress.Offset
+	}
+	progress.CompletedAt = time.Now()
+	if err := im.store.SaveImportProgress(ctx, progress); err != nil {
+		return progress, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("import completed",
+		slog.Int64("lines", progress.Line),
+		slog.Int64("imported", progress.Imported),
+		slog.Int64("duplicates", progress.Duplicates),
+		slog.Int64("conflicts", progress.Conflicts),
+		slog.Int64("invalid", progress.Invalid),
+		slog.Duration("took", time.Since(start)),
+	)
+	return progress, nil
+}
+
+// validate applies the rules of POST /save. A status may be given for
+// historical orders but must be a known one.
+func validate(order storage.Order) (map[string]string, error) {
+	if errs, err := resp.ValidateOrder(order, resp.LangEN); errs != nil || err != nil {
+		return errs, err
+	}
+
+	if order.Status != "" && !order.Status.Valid() {
+		return map[string]string{"status": fmt.Sprintf("unknown status %q", order.Status)}, nil
+	}
+	return nil, nil
+}
+
+func (im *Importer) reject(r Reject) {
+	im.log.Debug("line rejected",
+		slog.String("file", r.File),
+		slog.Int64("line", r.Line),
+		slog.String("reason", r.Reason),
+	)
+	if im.rejects == nil {
+		return
+	}
+	if err := im.rejects.Encode(r); err != nil {
+		im.log.Warn("failed to write reject", slog.Int64("line", r.Line), sl.Err(err))
+	}
+}
+
+func (im *Importer) report(log *slog.Logger, p storage.ImportProgress, first int64, start time.Time) {
+	var percent float64
+	if p.Size > 0 {
+		percent = float64(p.Offset) * 100 / float64(p.Size)
+	}
+
+	var rate float64
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		rate = float64(p.Line-first) / elapsed
+	}
+
+	log.Info("import progress",
+		slog.Int64("line", p.Line),
+		slog.String("done", fmt.Sprintf("%.1f%%", percent)),
+		slog.String("rate", fmt.Sprintf("%.0f lines/s", rate)),
+		slog.Int64("imported", p.Imported),
+		slog.Int64("duplicates", p.Duplicates),
+		slog.Int64("conflicts", p.Conflicts),
+		slog.Int64("invalid", p.Invalid),
+	)
+}
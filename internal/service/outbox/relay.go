@@ -0,0 +1,191 @@
+package orderOutbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/kafka"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+const (
+	publishTimeout  = 10 * time.Second
+	cleanupInterval = time.Minute
+)
+
+type Store interface {
+	PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	MarkOutboxPublished(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error
+	DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error)
+	WithOutboxLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+}
+
+type Publisher interface {
+	ProduceEvent(ctx context.Context, topic, key string, env kafka.Envelope) error
+}
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+	// Retention keeps published messages around for inspection.
+	Retention time.Duration
+	Retry     kafka.RetryPolicy
+}
+
+// Relay publishes outbox messages to Kafka in insertion order per key,
+// retrying failures with backoff, and removes published messages once they
+// are older than the retention.
+type Relay struct {
+	log       *slog.Logger
+	store     Store
+	publisher Publisher
+	cfg       Config
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	lastCleanup time.Time
+}
+
+func New(log *slog.Logger, store Store, publisher Publisher, cfg Config) *Relay {
+	return &Relay{
+		log:       log.With(slog.String("component", "outbox_relay")),
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *Relay) Start() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stop
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		locked, err := r.store.WithOutboxLock(ctx, r.tick)
+		if err != nil && ctx.Err() == nil {
+			r.log.Error("outbox relay failed", sl.Err(err))
+		}
+		if !locked && err == nil {
+			r.log.Debug("outbox is relayed by another replica")
+		}
+	}
+}
+
+// Stop waits for the batch in progress, if any, to finish.
+func (r *Relay) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for outbox relay: %w", ctx.Err())
+	}
+}
+
+// tick drains every message that is due, batch by batch.
+func (r *Relay) tick(ctx context.Context) error {
+	for {
+		msgs, err := r.store.PendingOutbox(ctx, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		if err := r.publish(ctx, msgs); err != nil {
+			return err
+		}
+
+		if len(msgs) < r.cfg.BatchSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return r.cleanup(ctx)
+}
+
+func (r *Relay) publish(ctx context.Context, msgs []storage.OutboxMessage) error {
+	// Once a message fails, later messages with the same key must wait for it.
+	blocked := make(map[string]bool)
+
+	for _, m := range msgs {
+		if blocked[m.Key] {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log := r.log.With(slog.String("event_id", m.EventID), slog.Int("attempt", m.Attempts+1))
+
+		err := r.send(ctx, m)
+		if err == nil {
+			if err := r.store.MarkOutboxPublished(ctx, m.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		blocked[m.Key] = true
+		retryAt := time.Now().Add(r.cfg.Retry.Backoff(m.Attempts + 1))
+		log.Warn("failed to publish outbox message", sl.Err(err), slog.Time("retry_at", retryAt))
+
+		if err := r.store.MarkOutboxFailed(ctx, m.ID, err, retryAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Relay) send(ctx context.Context, m storage.OutboxMessage) error {
+	var env kafka.Envelope
+	if err := json.Unmarshal(m.Value, &env); err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrEnvelope, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	return r.publisher.ProduceEvent(ctx, m.Topic, m.Key, env)
+}
+
+func (r *Relay) cleanup(ctx context.Context) error {
+	if time.Since(r.lastCleanup) < cleanupInterval {
+		return nil
+	}
+
+	n, err := r.store.DeletePublishedOutbox(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		return err
+	}
+	r.lastCleanup = time.Now()
+
+	if n > 0 {
+		r.log.Info("published outbox messages removed", slog.Int64("count", n))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package orderPublisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/kafka"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type Mode string
+
+const (
+	// ModeSync waits for the broker to acknowledge every order.
+	ModeSync Mode = "sync"
+	// ModeAsync queues orders in the producer and reports failures later.
+	ModeAsync Mode = "async"
+	// ModeOutbox stores orders in the outbox table for the relay to publish.
+	ModeOutbox Mode = "outbox"
+)
+
+var ErrUnknownMode = errors.New("unknown publish mode")
+
+type Producer interface {
+	ProduceEvent(ctx context.Context, topic, key string, env kafka.Envelope) error
+	ProduceEventAsync(ctx context.Context, topic, key string, env kafka.Envelope, done kafka.DeliveryFunc) error
+}
+
+type Outbox interface {
+	EnqueueOutbox(ctx context.Context, msg storage.OutboxMessage) error
+}
+
+type Config struct {
+	Mode   Mode
+	Topic  string
+	Key    storage.PartitionKey
+	Source string
+}
+
+// Receipt identifies an accepted order. Queued means the order is not yet
+// acknowledged by Kafka and may still be delivered later.
+type Receipt struct {
+	EventID string
+	Queued  bool
+}
+
+type Publisher struct {
+	log      *slog.Logger
+	producer Producer
+	outbox   Outbox
+	cfg      Config
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeSync, ModeAsync, ModeOutbox:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownMode, s)
+}
+
+// New creates a Publisher. producer may be nil in outbox mode, outbox may be
+// nil otherwise.
+func New(log *slog.Logger, producer Producer, outbox Outbox, cfg Config) *Publisher {
+	return &Publisher{
+		log:      log,
+		producer: producer,
+		outbox:   outbox,
+		cfg:      cfg,
+	}
+}
+
+func (p *Publisher) PublishOrder(ctx context.Context, order storage.Order) (Receipt, error) {
+	const op = "orderPublisher.PublishOrder"
+
+	env, err := p.orderCreated(order)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	key := p.cfg.Key.OfOrder(order)
+
+	switch p.cfg.Mode {
+	case ModeOutbox:
+		msg, err := p.OutboxMessage(Event{Key: key, Envelope: env})
+		if err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := p.outbox.EnqueueOutbox(ctx, msg); err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, unavailable(err, storage.ErrUnavailable))
+		}
+		return Receipt{EventID: env.EventID, Queued: true}, nil
+
+	case ModeAsync:
+		if p.producer == nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, kafka.ErrUnavailable)
+		}
+		err := p.producer.ProduceEventAsync(ctx, p.cfg.Topic, key, env, func(err error) {
+			if err != nil {
+				p.log.Error("failed to deliver order",
+					slog.String("order_uid", order.OrderUID),
+					slog.String("event_id", env.EventID),
+					sl.Err(err))
+			}
+		})
+		if err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, unavailable(err, kafka.ErrUnavailable))
+		}
+		return Receipt{EventID: env.EventID, Queued: true}, nil
+
+	default:
+		if p.producer == nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, kafka.ErrUnavailable)
+		}
+		if err := p.producer.ProduceEvent(ctx, p.cfg.Topic, key, env); err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", op, unavailable(err, kafka.ErrUnavailable))
+		}
+		return Receipt{EventID: env.EventID}, nil
+	}
+}
+
+// orderCreated wraps order into an OrderCreated event. New orders always
+// start as created, so a status sent by the client is dropped.
+func (p *Publisher) orderCreated(order storage.Order) (kafka.Envelope, error) {
+	order.Status = ""
+	return kafka.NewEnvelope(kafka.EventOrderCreated, p.cfg.Source, order)
+}
+
+// Event is an event about a stored order, keyed like the order's other
+// events so it lands on the same partition after them.
+type Event struct {
+	Key      string
+	Envelope kafka.Envelope
+}
+
+// StatusChanged builds the OrderStatusChanged event for change.
+func (p *Publisher) StatusChanged(change storage.StatusChange) (Event, error) {
+	env, err := kafka.NewEnvelope(kafka.EventOrderStatusChanged, p.cfg.Source, kafka.StatusChangedPayload{
+		OrderUID:  change.OrderUID,
+		From:      string(change.From),
+		To:        string(change.To),
+		ChangedAt: change.ChangedAt,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Key: p.cfg.Key.OfChange(change), Envelope: env}, nil
+}
+
+// OrderDeleted builds the OrderDeleted event for order. Only the fields the
+// partition key is taken from need to be set.
+func (p *Publisher) OrderDeleted(order storage.Order, deletedAt time.Time) (Event, error) {
+	env, err := kafka.NewEnvelope(kafka.EventOrderDeleted, p.cfg.Source, kafka.OrderDeletedPayload{
+		OrderUID:  order.OrderUID,
+		DeletedAt: deletedAt,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Key: p.cfg.Key.OfOrder(order), Envelope: env}, nil
+}
+
+// OutboxMessage encodes e for the outbox table, so a store can enqueue it in
+// the transaction that makes the change it describes.
+func (p *Publisher) OutboxMessage(e Event) (storage.OutboxMessage, error) {
+	value, err := json.Marshal(e.Envelope)
+	if err != nil {
+		return storage.OutboxMessage{}, fmt.Errorf("%w: %v", kafka.ErrEnvelope, err)
+	}
+	return storage.OutboxMessage{
+		EventID: e.Envelope.EventID,
+		Topic:   p.cfg.Topic,
+		Key:     e.Key,
+		Value:   value,
+	}, nil
+}
+
+// PublishEvent publishes e through the outbox in outbox mode and waits for
+// the broker otherwise, so a failure can be reported to the caller.
+func (p *Publisher) PublishEvent(ctx context.Context, e Event) error {
+	const op = "orderPublisher.PublishEvent"
+
+	if p.cfg.Mode == ModeOutbox {
+		msg, err := p.OutboxMessage(e)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := p.outbox.EnqueueOutbox(ctx, msg); err != nil {
+			return fmt.Errorf("%s: %w", op, unavailable(err, storage.ErrUnavailable))
+		}
+		return nil
+	}
+
+	if p.producer == nil {
+		return fmt.Errorf("%s: %w", op, kafka.ErrUnavailable)
+	}
+	if err := p.producer.ProduceEvent(ctx, p.cfg.Topic, e.Key, e.Envelope); err != nil {
+		return fmt.Errorf("%s: %w", op, unavailable(err, kafka.ErrUnavailable))
+	}
+	return nil
+}
+
+// unavailable marks a deadline hit while waiting for a dependency with that
+// dependency's sentinel, so callers can tell whether Kafka or the database
+// was too slow.
+func unavailable(err, sentinel error) error {
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// Result is the outcome of one order in PublishOrders.
+type Result struct {
+	Receipt
+	Err error
+}
+
+// PublishOrders publishes orders and returns one result per order, in order.
+// In sync mode every order is handed to the producer before waiting so Kafka
+// can batch them; orders still in flight when ctx is done are reported as
+// queued and any later failure is only logged.
+func (p *Publisher) PublishOrders(ctx context.Context, orders []storage.Order) []Result {
+	const op = "orderPublisher.PublishOrders"
+
+	results := make([]Result, len(orders))
+	if p.cfg.Mode != ModeSync {
+		for i, order := range orders {
+			receipt, err := p.PublishOrder(ctx, order)
+			results[i] = Result{Receipt: receipt, Err: err}
+		}
+		return results
+	}
+
+	if p.producer == nil {
+		for i := range results {
+			results[i].Err = fmt.Errorf("%s: %w", op, kafka.ErrUnavailable)
+		}
+		return results
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		reported = make([]bool, len(orders))
+		returned bool
+	)
+	// report records the outcome of order i once; a failed Produce may also
+	// call the delivery callback, so both paths go through here.
+	report := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if reported[i] {
+			return
+		}
+		reported[i] = true
+		if returned {
+			if err != nil {
+				p.log.Error("failed to deliver order",
+					slog.String("order_uid", orders[i].OrderUID),
+					slog.String("event_id", results[i].EventID),
+					sl.Err(err))
+			}
+		} else if err != nil {
+			results[i].Err = fmt.Errorf("%s: %w", op, unavailable(err, kafka.ErrUnavailable))
+		}
+		wg.Done()
+	}
+
+	for i, order := range orders {
+		env, err := p.orderCreated(order)
+		if err != nil {
+			results[i].Err = fmt.Errorf("%s: %w", op, err)
+			reported[i] = true
+			continue
+		}
+		results[i].EventID = env.EventID
+
+		wg.Add(1)
+		err = p.producer.ProduceEventAsync(ctx, p.cfg.Topic, p.cfg.Key.OfOrder(order), env, func(err error) {
+			report(i, err)
+		})
+		if err != nil {
+			report(i, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	returned = true
+	for i := range results {
+		if !reported[i] {
+			results[i].Queued = true
+		}
+	}
+	return append([]Result(nil), results...)
+}
@@ -0,0 +1,76 @@
+package orderSaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+var (
+	ErrDecode       = errors.New("failed to unmarshal message")
+	ErrInvalidOrder = errors.New("order failed validation")
+)
+
+// ValidationError lists the failed checks of an order keyed by JSON field
+// path. It is always wrapped in a PermanentError.
+type ValidationError struct {
+	OrderUID string
+	Errors   map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: order_uid=%q, %d invalid fields", ErrInvalidOrder, e.OrderUID, len(e.Errors))
+}
+
+func (e *ValidationError) Unwrap() error { return ErrInvalidOrder }
+
+// FieldErrors lets the dead-letter publisher attach the failures to the
+// message without depending on this package.
+func (e *ValidationError) FieldErrors() map[string]string { return e.Errors }
+
+// PermanentError wraps failures that will repeat on every redelivery of the
+// same message, such as malformed payloads or constraint violations.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// TransientError wraps failures caused by the environment, e.g. a lost
+// database connection. The same message is expected to succeed later.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string   { return e.Err.Error() }
+func (e *TransientError) Unwrap() error   { return e.Err }
+func (e *TransientError) Temporary() bool { return true }
+
+func IsPermanent(err error) bool {
+	var target *PermanentError
+	return errors.As(err, &target)
+}
+
+func IsTransient(err error) bool {
+	var target *TransientError
+	return errors.As(err, &target)
+}
+
+// classify wraps err into a PermanentError or TransientError. Anything not
+// known to be transient is permanent so that poison messages are not retried
+// forever.
+func classify(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case IsPermanent(err), IsTransient(err):
+		return err
+	case errors.Is(err, storage.ErrUnavailable),
+		errors.Is(err, context.DeadlineExceeded):
+		return &TransientError{Err: err}
+	}
+	return &PermanentError{Err: err}
+}
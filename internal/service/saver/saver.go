@@ -3,50 +3,97 @@ package orderSaver
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 
+	"github.com/srKazuya/ordersPET/internal/cache"
 	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	"github.com/srKazuya/ordersPET/internal/lib/validators"
 	"github.com/srKazuya/ordersPET/internal/storage"
 )
 
+var tracer = otel.Tracer("github.com/srKazuya/ordersPET/internal/service/saver")
+
 type Saver struct {
 	log     *slog.Logger
 	storage OrderSaver
+	cache   cache.OrderCache
 }
 
 type OrderSaver interface {
 	SaveOrder(ctx context.Context, order *storage.Order) error
 }
 
-func New(log *slog.Logger, saver OrderSaver) *Saver {
+func New(log *slog.Logger, saver OrderSaver, cache cache.OrderCache) *Saver {
 	return &Saver{
 		log:     log,
 		storage: saver,
+		cache:   cache,
 	}
 }
 
-func (s *Saver) SaveOrder(msg []byte, offset *kafka.Offset) error {
-	const op = "orderSaver.GetOrder"
+// SaveOrder stores the order carried by an OrderCreated event payload.
+func (s *Saver) SaveOrder(ctx context.Context, msg []byte) (err error) {
+	const op = "orderSaver.SaveOrder"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
 	var order storage.Order
 	if err := json.Unmarshal(msg, &order); err != nil {
-		s.log.Error("failed to unmarshal kafka msg", sl.Err(err))
-		return fmt.Errorf("%s: failed to unmarshal message: %w", op, err)
+		s.log.ErrorContext(ctx, "failed to unmarshal kafka msg", sl.Err(err))
+		return &PermanentError{Err: fmt.Errorf("%s: %w: %w", op, ErrDecode, err)}
 	}
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := validate(&order); err != nil {
+		s.log.ErrorContext(ctx, "invalid order", slog.String("order_id", order.OrderUID), sl.Err(err))
+		return &PermanentError{Err: fmt.Errorf("%s: %w", op, err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	// A status change or delete landing between the save and the cache write
+	// must win over the order being saved.
+	gen := s.cache.Generation(order.OrderUID)
 	if err := s.storage.SaveOrder(ctx, &order); err != nil {
-		s.log.Error("failed to save order", sl.Err(err))
-		return fmt.Errorf("%s: failed to save order: %w", op, err)
+		if errors.Is(err, storage.ErrOrderConflict) || errors.Is(err, storage.ErrPaymentConflict) {
+			s.log.ErrorContext(ctx, "order conflicts with a stored order",
+				slog.String("order_id", order.OrderUID), sl.Err(err))
+			return &PermanentError{Err: fmt.Errorf("%s: %w", op, err)}
+		}
+		s.log.ErrorContext(ctx, "failed to save order", sl.Err(err))
+		return classify(fmt.Errorf("%s: failed to save order: %w", op, err))
 	}
 
-	s.log.Info("order saver successfully", slog.String("order_id", order.OrderUID))
+	s.cache.SetIfCurrent(order, gen)
+
+	s.log.InfoContext(ctx, "order saver successfully", slog.String("order_id", order.OrderUID))
 	return nil
+}
 
+// validate applies the same tag and business checks as the save handler, so
+// orders published straight to the topic cannot bypass them.
+func validate(order *storage.Order) error {
+	errs, err := validators.ValidateOrder(*order, validators.LangEN)
+	if err != nil {
+		return err
+	}
+	if errs != nil {
+		return &ValidationError{OrderUID: order.OrderUID, Errors: errs}
+	}
+	return nil
 }
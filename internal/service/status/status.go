@@ -0,0 +1,97 @@
+package orderStatus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/srKazuya/ordersPET/internal/cache"
+	"github.com/srKazuya/ordersPET/internal/lib/logger/sl"
+	orderPublisher "github.com/srKazuya/ordersPET/internal/service/publisher"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type Updater struct {
+	log       *slog.Logger
+	storage   StatusUpdater
+	outbox    OutboxUpdater
+	cache     cache.OrderCache
+	publisher EventPublisher
+}
+
+type StatusUpdater interface {
+	UpdateOrderStatus(ctx context.Context, orderUID string, to storage.OrderStatus) (storage.StatusChange, error)
+}
+
+// OutboxUpdater changes a status and enqueues its event in one transaction.
+type OutboxUpdater interface {
+	UpdateOrderStatusWithOutbox(ctx context.Context, orderUID string, to storage.OrderStatus, announce func(storage.StatusChange) (storage.OutboxMessage, error)) (storage.StatusChange, error)
+}
+
+type EventPublisher interface {
+	StatusChanged(change storage.StatusChange) (orderPublisher.Event, error)
+	OutboxMessage(e orderPublisher.Event) (storage.OutboxMessage, error)
+	PublishEvent(ctx context.Context, e orderPublisher.Event) error
+}
+
+// New creates an Updater. With a non-nil outbox status changes are announced
+// through it in the transaction that makes them; otherwise they are
+// published once committed, and a failure only reaches the log.
+func New(log *slog.Logger, updater StatusUpdater, outbox OutboxUpdater, cache cache.OrderCache, publisher EventPublisher) *Updater {
+	return &Updater{
+		log:       log,
+		storage:   updater,
+		outbox:    outbox,
+		cache:     cache,
+		publisher: publisher,
+	}
+}
+
+func (u *Updater) UpdateOrderStatus(ctx context.Context, orderUID string, to storage.OrderStatus) (storage.StatusChange, error) {
+	const op = "orderStatus.UpdateOrderStatus"
+
+	if u.outbox != nil {
+		change, err := u.outbox.UpdateOrderStatusWithOutbox(ctx, orderUID, to, u.outboxMessage)
+		if err != nil {
+			return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+		}
+		u.invalidate(orderUID)
+		return change, nil
+	}
+
+	change, err := u.storage.UpdateOrderStatus(ctx, orderUID, to)
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.invalidate(orderUID)
+	u.announce(ctx, change)
+
+	return change, nil
+}
+
+func (u *Updater) invalidate(orderUID string) {
+	u.cache.Delete(orderUID)
+	u.log.Info("order cache invalidated", slog.String("order_id", orderUID))
+}
+
+func (u *Updater) outboxMessage(change storage.StatusChange) (storage.OutboxMessage, error) {
+	e, err := u.publisher.StatusChanged(change)
+	if err != nil {
+		return storage.OutboxMessage{}, err
+	}
+	return u.publisher.OutboxMessage(e)
+}
+
+// announce publishes the change for other services and replicas. The change
+// is already committed, so a failure is logged rather than returned.
+func (u *Updater) announce(ctx context.Context, change storage.StatusChange) {
+	e, err := u.publisher.StatusChanged(change)
+	if err == nil {
+		err = u.publisher.PublishEvent(ctx, e)
+	}
+	if err != nil {
+		u.log.ErrorContext(ctx, "failed to publish status change",
+			slog.String("order_id", change.OrderUID), sl.Err(err))
+	}
+}
@@ -0,0 +1,11 @@
+package storage
+
+import "errors"
+
+var (
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrOrderExists     = errors.New("order already exists")
+	ErrOrderConflict   = errors.New("order_uid is already used by a different order")
+	ErrPaymentConflict = errors.New("payment transaction is already used by a different order")
+	ErrUnavailable     = errors.New("storage unavailable")
+)
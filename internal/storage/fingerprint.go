@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Fingerprint hashes the order payload so that a redelivered copy can be told
+// apart from a different order reusing the same order_uid. The status is left
+// out because it changes after ingestion.
+func Fingerprint(order Order) string {
+	order.Status = ""
+	order.DateCreated = order.DateCreated.UTC().Truncate(time.Microsecond)
+
+	raw, _ := json.Marshal(order)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "time"
+
+// ImportProgress is the saved position of a bulk import of one file. Offset
+// is the byte offset right after Line, so a resumed import seeks there.
+// Progress is committed together with the orders it covers.
+type ImportProgress struct {
+	File        string
+	Size        int64
+	Offset      int64
+	Line        int64
+	Imported    int64
+	Duplicates  int64
+	Conflicts   int64
+	Invalid     int64
+	CompletedAt time.Time
+}
+
+func (p ImportProgress) Completed() bool {
+	return !p.CompletedAt.IsZero()
+}
+
+// ImportRecord is a validated order read from line Line of an import file.
+type ImportRecord struct {
+	Line  int64
+	Order Order
+}
+
+// Keys an imported order can collide on with a different order.
+const (
+	ImportKeyOrderUID    = "order_uid"
+	ImportKeyTransaction = "payment.transaction"
+)
+
+// ImportConflict is an imported line whose Key, order_uid or the payment
+// transaction, is already used by a different order.
+type ImportConflict struct {
+	Line     int64
+	OrderUID string
+	Key      string
+}
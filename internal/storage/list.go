@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	DateFrom        time.Time
+	DateTo          time.Time
+	Currency        string
+	Provider        string
+	Brand           string
+}
+
+type ListParams struct {
+	Filter OrderFilter
+	Cursor string
+	Limit  int
+}
+
+type OrdersPage struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// Cursor points at the last order of a page. Orders are listed newest first,
+// ties on date_created are broken by order_uid.
+type Cursor struct {
+	DateCreated time.Time `json:"d"`
+	OrderUID    string    `json:"u"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.OrderUID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (p ListParams) PageLimit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultListLimit
+	case p.Limit > MaxListLimit:
+		return MaxListLimit
+	}
+	return p.Limit
+}
@@ -0,0 +1,291 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+var _ storage.Repository = (*Storage)(nil)
+
+// Storage keeps orders in process memory. It mirrors postgres.Storage,
+// including idempotent saves, status transitions and the listing order, so
+// the service can run locally without a database. Nothing is persisted.
+type Storage struct {
+	mu     sync.RWMutex
+	orders map[string]entry
+	// payments maps a payment transaction to the order_uid holding it, like
+	// the primary key of the payments table.
+	payments map[string]string
+}
+
+type entry struct {
+	order storage.Order
+	hash  string
+}
+
+func New() *Storage {
+	return &Storage{
+		orders:   make(map[string]entry),
+		payments: make(map[string]string),
+	}
+}
+
+func (s *Storage) SaveOrder(ctx context.Context, order *storage.Order) error {
+	const op = "storage.memory.SaveOrder"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if order.Status == "" {
+		order.Status = storage.StatusCreated
+	}
+	if !order.Status.Valid() {
+		return fmt.Errorf("%s: %w: %q", op, storage.ErrUnknownStatus, order.Status)
+	}
+
+	hash := storage.Fingerprint(*order)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.orders[order.OrderUID]; ok {
+		if e.hash != hash {
+			return fmt.Errorf("%s: %w: %s", op, storage.ErrOrderConflict, order.OrderUID)
+		}
+		order.Status = e.order.Status
+		return nil
+	}
+	if _, ok := s.payments[order.Payment.Transaction]; ok {
+		return fmt.Errorf("%s: %w: %s", op, storage.ErrPaymentConflict, order.Payment.Transaction)
+	}
+
+	stored := clone(*order)
+	stored.DateCreated = asTimestamp(stored.DateCreated)
+	s.orders[order.OrderUID] = entry{order: stored, hash: hash}
+	s.payments[order.Payment.Transaction] = order.OrderUID
+	return nil
+}
+
+func (s *Storage) GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error) {
+	const op = "storage.memory.GetOrderByUID"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.orders[orderUID]
+	if !ok {
+		return storage.Order{}, fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
+	}
+	return clone(e.order), nil
+}
+
+func (s *Storage) GetOrdersByUIDs(ctx context.Context, orderUIDs []string) ([]storage.Order, error) {
+	const op = "storage.memory.GetOrdersByUIDs"
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(orderUIDs) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []storage.Order
+	for _, uid := range orderUIDs {
+		if e, ok := s.orders[uid]; ok {
+			orders = append(orders, clone(e.order))
+		}
+	}
+	return orders, nil
+}
+
+func (s *Storage) ListOrders(ctx context.Context, params storage.ListParams) (storage.OrdersPage, error) {
+	const op = "storage.memory.ListOrders"
+
+	if err := ctx.Err(); err != nil {
+		return storage.OrdersPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var after *storage.Cursor
+	if params.Cursor != "" {
+		cursor, err := storage.DecodeCursor(params.Cursor)
+		if err != nil {
+			return storage.OrdersPage{}, fmt.Errorf("%s: %w", op, err)
+		}
+		after = &cursor
+	}
+
+	orders := s.matching(params.Filter)
+	if after != nil {
+		i := slices.IndexFunc(orders, func(o storage.Order) bool { return pastCursor(o, *after) })
+		if i < 0 {
+			i = len(orders)
+		}
+		orders = orders[i:]
+	}
+
+	page := storage.OrdersPage{Orders: []storage.Order{}}
+	limit := params.PageLimit()
+	if len(orders) > limit {
+		orders = orders[:limit]
+		last := orders[limit-1]
+		page.NextCursor = storage.EncodeCursor(storage.Cursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID})
+	}
+	page.Orders = append(page.Orders, orders...)
+
+	return page, nil
+}
+
+// ExportOrders passes the orders matching f to fn, newest first, from a
+// snapshot taken when the export starts.
+func (s *Storage) ExportOrders(ctx context.Context, f storage.OrderFilter, fn func(storage.Order) error) error {
+	const op = "storage.memory.ExportOrders"
+
+	for _, order := range s.matching(f) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) UpdateOrderStatus(ctx context.Context, orderUID string, to storage.OrderStatus) (storage.StatusChange, error) {
+	const op = "storage.memory.UpdateOrderStatus"
+
+	if err := ctx.Err(); err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.orders[orderUID]
+	if !ok {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
+	}
+
+	if err := storage.CheckTransition(e.order.Status, to); err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	change := storage.StatusChange{
+		OrderUID:   orderUID,
+		From:       e.order.Status,
+		To:         to,
+		ChangedAt:  time.Now(),
+		CustomerID: e.order.CustomerID,
+		ShardKey:   e.order.ShardKey,
+	}
+
+	e.order.Status = to
+	s.orders[orderUID] = e
+	return change, nil
+}
+
+func (s *Storage) DeleteOrder(ctx context.Context, orderUID string) error {
+	const op = "storage.memory.DeleteOrder"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.orders[orderUID]
+	if !ok {
+		return fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
+	}
+	delete(s.orders, orderUID)
+	delete(s.payments, e.order.Payment.Transaction)
+	return nil
+}
+
+func (s *Storage) Ping(context.Context) error {
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+// matching returns copies of the orders matching f, newest first with ties
+// broken by order_uid descending, like the postgres listing. Uids compare
+// bytewise, which agrees with the database collation for lowercase uids.
+func (s *Storage) matching(f storage.OrderFilter) []storage.Order {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []storage.Order
+	for _, e := range s.orders {
+		if matches(e.order, f) {
+			orders = append(orders, clone(e.order))
+		}
+	}
+
+	slices.SortFunc(orders, func(a, b storage.Order) int {
+		return -compareKeys(a.DateCreated, a.OrderUID, b.DateCreated, b.OrderUID)
+	})
+	return orders
+}
+
+func matches(o storage.Order, f storage.OrderFilter) bool {
+	switch {
+	case f.CustomerID != "" && o.CustomerID != f.CustomerID,
+		f.DeliveryService != "" && o.DeliveryService != f.DeliveryService,
+		f.Locale != "" && o.Locale != f.Locale,
+		!f.DateFrom.IsZero() && o.DateCreated.Before(asTimestamp(f.DateFrom)),
+		!f.DateTo.IsZero() && !o.DateCreated.Before(asTimestamp(f.DateTo)),
+		f.Currency != "" && o.Payment.Currency != f.Currency,
+		f.Provider != "" && o.Payment.Provider != f.Provider:
+		return false
+	}
+
+	if f.Brand != "" {
+		return slices.ContainsFunc(o.Items, func(item storage.Item) bool {
+			return item.Brand == f.Brand
+		})
+	}
+	return true
+}
+
+// pastCursor reports whether o comes after the cursor in listing order, that
+// is (date_created, order_uid) < cursor.
+func pastCursor(o storage.Order, c storage.Cursor) bool {
+	return compareKeys(o.DateCreated, o.OrderUID, c.DateCreated, c.OrderUID) < 0
+}
+
+func compareKeys(t1 time.Time, uid1 string, t2 time.Time, uid2 string) int {
+	if c := t1.Compare(t2); c != 0 {
+		return c
+	}
+	return cmp.Compare(uid1, uid2)
+}
+
+// asTimestamp stores t the way a PostgreSQL TIMESTAMP column does: the wall
+// clock without its zone, rounded to microseconds.
+func asTimestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Round(time.Microsecond)
+}
+
+func clone(o storage.Order) storage.Order {
+	o.Items = slices.Clone(o.Items)
+	return o
+}
@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/memory"
+	"github.com/srKazuya/ordersPET/internal/storage/storagetest"
+)
+
+func TestRepository(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Repository { return memory.New() })
+}
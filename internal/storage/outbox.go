@@ -0,0 +1,16 @@
+package storage
+
+import "time"
+
+// OutboxMessage is an event accepted by the HTTP API and waiting to be
+// published to Kafka. Value holds the encoded envelope, so retries publish
+// the very same event.
+type OutboxMessage struct {
+	ID        int64
+	EventID   string
+	Topic     string
+	Key       string
+	Value     []byte
+	Attempts  int
+	CreatedAt time.Time
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PartitionKey names the order field Kafka messages are keyed by. All events
+// of one order must share a key so they land on one partition in order.
+type PartitionKey string
+
+const (
+	KeyOrderUID   PartitionKey = "order_uid"
+	KeyCustomerID PartitionKey = "customer_id"
+	KeyShardKey   PartitionKey = "shardkey"
+)
+
+var ErrUnknownPartitionKey = errors.New("unknown partition key")
+
+func ParsePartitionKey(s string) (PartitionKey, error) {
+	switch k := PartitionKey(s); k {
+	case KeyOrderUID, KeyCustomerID, KeyShardKey:
+		return k, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownPartitionKey, s)
+}
+
+func (k PartitionKey) OfOrder(o Order) string {
+	switch k {
+	case KeyCustomerID:
+		return o.CustomerID
+	case KeyShardKey:
+		return o.ShardKey
+	}
+	return o.OrderUID
+}
+
+func (k PartitionKey) OfChange(c StatusChange) string {
+	switch k {
+	case KeyCustomerID:
+		return c.CustomerID
+	case KeyShardKey:
+		return c.ShardKey
+	}
+	return c.OrderUID
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+const pqUniqueViolation = "23505"
+
+// classify tags driver errors with the storage sentinels callers branch on.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == pqUniqueViolation:
+			return fmt.Errorf("%w: %w", storage.ErrOrderExists, err)
+		case unavailableCode(pqErr.Code):
+			return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// unavailableCode reports connection exceptions (class 08), transaction
+// rollbacks such as serialization failures and deadlocks (class 40), server
+// shutdowns and resource exhaustion, all of which go away without changing
+// the request.
+func unavailableCode(code pq.ErrorCode) bool {
+	switch code.Class() {
+	case "08", "40", "53", "57":
+		return code != "57014" // query_canceled
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+// exportFetchSize is the number of orders read from the cursor and loaded
+// per round trip.
+const exportFetchSize = 500
+
+// ExportOrders streams the orders matching f, newest first, to fn. Order
+// keys come from a server-side cursor and are loaded a chunk at a time inside
+// one read-only snapshot, so memory use does not depend on the number of
+// orders and the export is consistent. An error returned by fn stops the
+// export and is returned as is.
+func (s *Storage) ExportOrders(ctx context.Context, f storage.OrderFilter, fn func(storage.Order) error) error {
+	const op = "storage.postgres.ExportOrders"
+	defer observeQuery("export_orders", time.Now())
+
+	where, args := filterConditions(f, nil)
+
+	return s.readSnapshot(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DECLARE export_orders NO SCROLL CURSOR FOR
+			SELECT o.order_uid
+			FROM orders o
+			JOIN payments p ON p.order_uid = o.order_uid`+
+			whereClause(where)+`
+			ORDER BY o.date_created DESC, o.order_uid DESC`, args...)
+		if err != nil {
+			return fmt.Errorf("%s: declare cursor: %w", op, classify(err))
+		}
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_orders", exportFetchSize)
+		for {
+			uids, err := fetchKeys(ctx, tx, fetch)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			orders, err := loadOrders(ctx, tx, uids)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			for _, order := range orders {
+				if err := fn(order); err != nil {
+					return err
+				}
+			}
+
+			if len(uids) < exportFetchSize {
+				return nil
+			}
+		}
+	})
+}
+
+func fetchKeys(ctx context.Context, tx *sql.Tx, fetch string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("scan: %w", classify(err))
+		}
+		uids = append(uids, uid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate: %w", classify(err))
+	}
+	return uids, nil
+}
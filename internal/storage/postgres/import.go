@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// GetImportProgress returns the saved progress of file, or a fresh progress
+// when the file has never been imported.
+func (s *Storage) GetImportProgress(ctx context.Context, file string) (storage.ImportProgress, error) {
+	const op = "storage.postgres.GetImportProgress"
+	defer observeQuery("get_import_progress", time.Now())
+
+	p := storage.ImportProgress{File: file}
+	var completedAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT size, "offset", line, imported, duplicates, conflicts, invalid, completed_at
+		FROM import_progress WHERE file = $1
+	`, file).Scan(&p.Size, &p.Offset, &p.Line, &p.Imported, &p.Duplicates, &p.Conflicts, &p.Invalid, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, nil
+	}
+	if err != nil {
+		return storage.ImportProgress{}, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	p.CompletedAt = completedAt.Time
+	return p, nil
+}
+
+// SaveImportProgress stores p, replacing the earlier progress of the file.
+func (s *Storage) SaveImportProgress(ctx context.Context, p storage.ImportProgress) error {
+	const op = "storage.postgres.SaveImportProgress"
+	defer observeQuery("save_import_progress", time.Now())
+
+	if err := saveImportProgress(ctx, s.db, p); err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return nil
+}
+
+func saveImportProgress(ctx context.Context, db execer, p storage.ImportProgress) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO import_progress (file, size, "offset", line, imported, duplicates, conflicts, invalid, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (file) DO UPDATE SET
+			size = EXCLUDED.size,
+			"offset" = EXCLUDED."offset",
+			line = EXCLUDED.line,
+			imported = EXCLUDED.imported,
+			duplicates = EXCLUDED.duplicates,
+			conflicts = EXCLUDED.conflicts,
+			invalid = EXCLUDED.invalid,
+			completed_at = EXCLUDED.completed_at,
+			updated_at = now()
+	`, p.File, p.Size, p.Offset, p.Line, p.Imported, p.Duplicates, p.Conflicts, p.Invalid,
+		sql.NullTime{Time: p.CompletedAt, Valid: !p.CompletedAt.IsZero()})
+	return err
+}
+
+// ImportOrders bulk loads records: they are copied into temporary staging
+// tables with COPY and merged into the order tables in one transaction,
+// which also saves p advanced by the outcome. The first occurrence of an
+// order_uid wins. Later occurrences and orders already stored are counted as
+// duplicates when the payload matches and returned as conflicts otherwise;
+// rows stored before payload hashes existed always count as duplicates. A new
+// order whose payment transaction is already stored, or taken by an earlier
+// line, is skipped and returned as a conflict too, so one bad line cannot
+// fail the batch.
+func (s *Storage) ImportOrders(ctx context.Context, p storage.ImportProgress, records []storage.ImportRecord) (_ storage.ImportProgress, conflicts []storage.ImportConflict, err error) {
+	const op = "storage.postgres.ImportOrders"
+	defer observeQuery("import_orders", time.Now())
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return p, nil, fmt.Errorf("%s failed to begin transaction: %w", op, classify(err))
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("%s commit: %w", op, classify(err))
+		}
+	}()
+
+	var (
+		orderCols    = append(columns(&storage.Order{}), "payload_hash")
+		deliveryCols = append([]string{"order_uid"}, columns(&storage.Delivery{})...)
+		paymentCols  = append([]string{"order_uid"}, columns(&storage.Payment{})...)
+		itemCols     = append([]string{"order_uid"}, columns(&storage.Item{})...)
+	)
+
+	staging := []struct {
+		name, table string
+		extra, cols []string
+		rows        [][]any
+	}{
+		{name: "import_orders", table: "orders", extra: []string{"line"}, cols: orderCols},
+		{name: "import_deliveries", table: "deliveries", extra: []string{"line"}, cols: deliveryCols},
+		{name: "import_payments", table: "payments", extra: []string{"line"}, cols: paymentCols},
+		{name: "import_items", table: "items", extra: []string{"line", "pos"}, cols: itemCols},
+	}
+
+	for _, r := range records {
+		order := r.Order
+		if order.Status == "" {
+			order.Status = storage.StatusCreated
+		}
+
+		staging[0].rows = append(staging[0].rows,
+			append(append([]any{r.Line}, values(&order)...), storage.Fingerprint(order)))
+		staging[1].rows = append(staging[1].rows,
+			append([]any{r.Line, order.OrderUID}, values(&order.Delivery)...))
+		staging[2].rows = append(staging[2].rows,
+			append([]any{r.Line, order.OrderUID}, values(&order.Payment)...))
+		for i, item := range order.Items {
+			staging[3].rows = append(staging[3].rows,
+				append([]any{r.Line, i, order.OrderUID}, values(&item)...))
+		}
+	}
+
+	for _, st := range staging {
+		// CREATE TABLE AS copies the column types but none of the constraints.
+		extra := make([]string, len(st.extra))
+		for i, name := range st.extra {
+			extra[i] = "0::BIGINT AS " + name
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s, %s FROM %s WITH NO DATA",
+			st.name, strings.Join(extra, ", "), selectList(st.cols), st.table))
+		if err != nil {
+			return p, nil, fmt.Errorf("%s create %s: %w", op, st.name, classify(err))
+		}
+
+		if err = copyIn(ctx, tx, st.name, append(st.extra, st.cols...), st.rows); err != nil {
+			return p, nil, fmt.Errorf("%s copy into %s: %w", op, st.name, classify(err))
+		}
+	}
+
+	for _, name := range []string{"import_merged", "import_unpaid"} {
+		_, err = tx.ExecContext(ctx, `
+			CREATE TEMP TABLE `+name+` (order_uid TEXT PRIMARY KEY, line BIGINT NOT NULL) ON COMMIT DROP
+		`)
+		if err != nil {
+			return p, nil, fmt.Errorf("%s create %s: %w", op, name, classify(err))
+		}
+	}
+
+	// payments.transaction is unique: of the new orders sharing one, only the
+	// first line is kept, and none if a stored order already has it.
+	_, err = tx.ExecContext(ctx, `
+		WITH picked AS (
+			SELECT DISTINCT ON (s.order_uid) s.order_uid, s.line
+			FROM import_orders s
+			WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = s.order_uid)
+			ORDER BY s.order_uid, s.line
+		), paid AS (
+			SELECT DISTINCT ON (p.transaction) p.order_uid
+			FROM picked
+			JOIN import_payments p ON p.order_uid = picked.order_uid AND p.line = picked.line
+			WHERE NOT EXISTS (SELECT 1 FROM payments e WHERE e.transaction = p.transaction)
+			ORDER BY p.transaction, p.line
+		)
+		INSERT INTO import_unpaid (order_uid, line)
+		SELECT picked.order_uid, picked.line FROM picked
+		WHERE NOT EXISTS (SELECT 1 FROM paid WHERE paid.order_uid = picked.order_uid)
+	`)
+	if err != nil {
+		return p, nil, fmt.Errorf("%s find payment conflicts: %w", op, classify(err))
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		WITH picked AS (
+			SELECT DISTINCT ON (order_uid) * FROM import_orders ORDER BY order_uid, line
+		), inserted AS (
+			INSERT INTO orders (`+selectList(orderCols)+`)
+			SELECT `+selectList(qualified("picked", orderCols))+` FROM picked
+			WHERE NOT EXISTS (SELECT 1 FROM import_unpaid u WHERE u.order_uid = picked.order_uid)
+			ON CONFLICT (order_uid) DO NOTHING
+			RETURNING order_uid
+		)
+		INSERT INTO import_merged (order_uid, line)
+		SELECT picked.order_uid, picked.line FROM picked JOIN inserted USING (order_uid)
+	`)
+	if err != nil {
+		return p, nil, fmt.Errorf("%s merge orders: %w", op, classify(err))
+	}
+	imported, err := res.RowsAffected()
+	if err != nil {
+		return p, nil, fmt.Errorf("%s merge orders: %w", op, classify(err))
+	}
+
+	merges := []struct {
+		table, stage string
+		cols         []string
+		order        string
+	}{
+		{table: "deliveries", stage: "import_deliveries", cols: deliveryCols},
+		{table: "payments", stage: "import_payments", cols: paymentCols},
+		{table: "items", stage: "import_items", cols: itemCols, order: " ORDER BY s.line, s.pos"},
+	}
+	for _, m := range merges {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO `+m.table+` (`+selectList(m.cols)+`)
+			SELECT `+selectList(qualified("s", m.cols))+`
+			FROM `+m.stage+` s
+			JOIN import_merged m ON m.order_uid = s.order_uid AND m.line = s.line`+m.order)
+		if err != nil {
+			return p, nil, fmt.Errorf("%s merge %s: %w", op, m.table, classify(err))
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (order_uid, from_status, to_status)
+		SELECT s.order_uid, NULL, s.status
+		FROM import_orders s
+		JOIN import_merged m ON m.order_uid = s.order_uid AND m.line = s.line
+	`)
+	if err != nil {
+		return p, nil, fmt.Errorf("%s insert into order_status_history: %w", op, classify(err))
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT s.line, s.order_uid, '`+storage.ImportKeyOrderUID+`'
+		FROM import_orders s
+		JOIN orders o ON o.order_uid = s.order_uid
+		WHERE NOT EXISTS (SELECT 1 FROM import_merged m WHERE m.order_uid = s.order_uid AND m.line = s.line)
+		  AND o.payload_hash IS NOT NULL AND o.payload_hash <> s.payload_hash
+		UNION ALL
+		SELECT line, order_uid, '`+storage.ImportKeyTransaction+`' FROM import_unpaid
+		ORDER BY 1
+	`)
+	if err != nil {
+		return p, nil, fmt.Errorf("%s find conflicts: %w", op, classify(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c storage.ImportConflict
+		if err = rows.Scan(&c.Line, &c.OrderUID, &c.Key); err != nil {
+			return p, nil, fmt.Errorf("%s scan conflict: %w", op, classify(err))
+		}
+		conflicts = append(conflicts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return p, nil, fmt.Errorf("%s iterate conflicts: %w", op, classify(err))
+	}
+
+	next := p
+	next.Imported += imported
+	next.Conflicts += int64(len(conflicts))
+	next.Duplicates += int64(len(records)) - imported - int64(len(conflicts))
+
+	if err = saveImportProgress(ctx, tx, next); err != nil {
+		return p, nil, fmt.Errorf("%s save progress: %w", op, classify(err))
+	}
+
+	return next, conflicts, nil
+}
+
+func copyIn(ctx context.Context, tx *sql.Tx, table string, cols []string, rows [][]any) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, cols...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+
+	// An Exec without arguments flushes the buffered rows.
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+func (s *Storage) ListOrders(ctx context.Context, params storage.ListParams) (storage.OrdersPage, error) {
+	const op = "storage.postgres.ListOrders"
+	defer observeQuery("list_orders", time.Now())
+
+	where, args := filterConditions(params.Filter, nil)
+
+	if params.Cursor != "" {
+		cursor, err := storage.DecodeCursor(params.Cursor)
+		if err != nil {
+			return storage.OrdersPage{}, fmt.Errorf("%s: %w", op, err)
+		}
+		args = append(args, cursor.DateCreated, cursor.OrderUID)
+		where = append(where, fmt.Sprintf("(o.date_created, o.order_uid) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	limit := params.PageLimit()
+	args = append(args, limit+1)
+
+	query := `
+		SELECT o.order_uid, o.date_created
+		FROM orders o
+		JOIN payments p ON p.order_uid = o.order_uid` +
+		whereClause(where) + fmt.Sprintf(`
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $%d`, len(args))
+
+	var page storage.OrdersPage
+	err := s.readSnapshot(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("select orders: %w", classify(err))
+		}
+		defer rows.Close()
+
+		var keys []storage.Cursor
+		for rows.Next() {
+			var key storage.Cursor
+			if err := rows.Scan(&key.OrderUID, &key.DateCreated); err != nil {
+				return fmt.Errorf("scan order: %w", classify(err))
+			}
+			keys = append(keys, key)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate orders: %w", classify(err))
+		}
+
+		if len(keys) > limit {
+			keys = keys[:limit]
+			page.NextCursor = storage.EncodeCursor(keys[limit-1])
+		}
+
+		uids := make([]string, len(keys))
+		for i, key := range keys {
+			uids[i] = key.OrderUID
+		}
+		page.Orders, err = loadOrders(ctx, tx, uids)
+		if err != nil {
+			return err
+		}
+		if page.Orders == nil {
+			page.Orders = []storage.Order{}
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.OrdersPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+// filterConditions expects orders aliased as "o" and payments as "p".
+func filterConditions(f storage.OrderFilter, args []any) ([]string, []any) {
+	var where []string
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.CustomerID != "" {
+		add("o.customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("o.delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Locale != "" {
+		add("o.locale = $%d", f.Locale)
+	}
+	if !f.DateFrom.IsZero() {
+		add("o.date_created >= $%d", f.DateFrom)
+	}
+	if !f.DateTo.IsZero() {
+		add("o.date_created < $%d", f.DateTo)
+	}
+	if f.Currency != "" {
+		add("p.currency = $%d", f.Currency)
+	}
+	if f.Provider != "" {
+		add("p.provider = $%d", f.Provider)
+	}
+	if f.Brand != "" {
+		add("EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = $%d)", f.Brand)
+	}
+
+	return where, args
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return "\n\t\tWHERE " + strings.Join(where, " AND ")
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// mapping lists the db-tagged fields of a struct in declaration order.
+// Untagged fields, such as nested entities stored in their own tables, are
+// skipped.
+type mapping struct {
+	columns []string
+	index   []int
+}
+
+var mappings sync.Map // reflect.Type -> mapping
+
+func mappingOf(t reflect.Type) mapping {
+	if m, ok := mappings.Load(t); ok {
+		return m.(mapping)
+	}
+
+	var m mapping
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("db")
+		if name == "" || name == "-" {
+			continue
+		}
+		m.columns = append(m.columns, name)
+		m.index = append(m.index, i)
+	}
+
+	// Callers append extra columns, which must not write into the cache.
+	m.columns = slices.Clip(m.columns)
+	mappings.Store(t, m)
+	return m
+}
+
+// columns returns the column names of the struct v points to.
+func columns(v any) []string {
+	return mappingOf(reflect.TypeOf(v).Elem()).columns
+}
+
+// values returns the mapped field values of the struct v points to, in
+// column order.
+func values(v any) []any {
+	rv := reflect.ValueOf(v).Elem()
+	m := mappingOf(rv.Type())
+
+	result := make([]any, len(m.index))
+	for i, idx := range m.index {
+		result[i] = rv.Field(idx).Interface()
+	}
+	return result
+}
+
+// dest returns pointers to the mapped fields of the struct v points to, in
+// column order, for use with Scan.
+func dest(v any) []any {
+	rv := reflect.ValueOf(v).Elem()
+	m := mappingOf(rv.Type())
+
+	result := make([]any, len(m.index))
+	for i, idx := range m.index {
+		result[i] = rv.Field(idx).Addr().Interface()
+	}
+	return result
+}
+
+func insertQuery(table string, columns []string) string {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+}
+
+func selectList(columns []string) string {
+	return strings.Join(columns, ", ")
+}
+
+// qualified prefixes every column with a table alias.
+func qualified(alias string, columns []string) []string {
+	result := make([]string, len(columns))
+	for i, c := range columns {
+		result[i] = alias + "." + c
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"io/fs"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/storagetest"
+)
+
+func TestMappingRoundTrip(t *testing.T) {
+	order := storagetest.FullOrder()
+
+	// Nested entities live in their own tables and are not mapped.
+	flat := order
+	flat.Delivery = storage.Delivery{}
+	flat.Payment = storage.Payment{}
+	flat.Items = nil
+
+	tests := []struct {
+		name string
+		src  any
+		want any
+		dst  any
+	}{
+		{name: "order", src: &order, want: &flat, dst: &storage.Order{}},
+		{name: "delivery", src: &order.Delivery, want: &order.Delivery, dst: &storage.Delivery{}},
+		{name: "payment", src: &order.Payment, want: &order.Payment, dst: &storage.Payment{}},
+		{name: "item", src: &order.Items[0], want: &order.Items[0], dst: &storage.Item{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, vals, ptrs := columns(tt.src), values(tt.src), dest(tt.dst)
+			if len(vals) != len(cols) || len(ptrs) != len(cols) {
+				t.Fatalf("%d columns, %d values, %d destinations", len(cols), len(vals), len(ptrs))
+			}
+
+			// Scan would copy each value into the matching destination.
+			for i, v := range vals {
+				reflect.ValueOf(ptrs[i]).Elem().Set(reflect.ValueOf(v))
+			}
+
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("round trip changed the %s\n got: %+v\nwant: %+v", tt.name, tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingColumnsExist(t *testing.T) {
+	tables := schema(t)
+
+	tests := []struct {
+		table string
+		v     any
+	}{
+		{table: "orders", v: &storage.Order{}},
+		{table: "deliveries", v: &storage.Delivery{}},
+		{table: "payments", v: &storage.Payment{}},
+		{table: "items", v: &storage.Item{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			cols, ok := tables[tt.table]
+			if !ok {
+				t.Fatalf("no migration creates table %s", tt.table)
+			}
+			for _, c := range columns(tt.v) {
+				if !cols[c] {
+					t.Errorf("db tag %q has no column in %s", c, tt.table)
+				}
+			}
+		})
+	}
+}
+
+var (
+	createTable = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+	addColumn   = regexp.MustCompile(`ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+)`)
+)
+
+// schema collects the columns each table has after all Up migrations.
+func schema(t *testing.T) map[string]map[string]bool {
+	t.Helper()
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+
+	tables := make(map[string]map[string]bool)
+	for _, file := range files {
+		raw, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		up, _, _ := strings.Cut(string(raw), "-- +goose Down")
+
+		for _, m := range createTable.FindAllStringSubmatch(up, -1) {
+			cols := make(map[string]bool)
+			for _, line := range strings.Split(m[2], "\n") {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					cols[strings.Trim(fields[0], `"`)] = true
+				}
+			}
+			tables[m[1]] = cols
+		}
+		for _, m := range addColumn.FindAllStringSubmatch(up, -1) {
+			tables[m[1]][m[2]] = true
+		}
+	}
+	return tables
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE INDEX IF NOT EXISTS orders_date_created_uid_idx ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+CREATE INDEX IF NOT EXISTS items_brand_idx ON items (brand);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS items_brand_idx;
+DROP INDEX IF EXISTS items_order_uid_idx;
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP INDEX IF EXISTS orders_date_created_uid_idx;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'created'
+	CHECK (status IN ('created', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+	from_status TEXT,
+	to_status TEXT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_uid_idx ON order_status_history (order_uid, changed_at);
+
+INSERT INTO order_status_history (order_uid, from_status, to_status)
+SELECT order_uid, NULL, status FROM orders;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS order_status_history;
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
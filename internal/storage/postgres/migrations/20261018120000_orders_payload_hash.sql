@@ -0,0 +1,7 @@
+-- +goose Up
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payload_hash TEXT;
+
+-- +goose Down
+
+ALTER TABLE orders DROP COLUMN IF EXISTS payload_hash;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS import_progress (
+	file TEXT PRIMARY KEY,
+	size BIGINT NOT NULL,
+	"offset" BIGINT NOT NULL DEFAULT 0,
+	line BIGINT NOT NULL DEFAULT 0,
+	imported BIGINT NOT NULL DEFAULT 0,
+	duplicates BIGINT NOT NULL DEFAULT 0,
+	conflicts BIGINT NOT NULL DEFAULT 0,
+	invalid BIGINT NOT NULL DEFAULT 0,
+	started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	completed_at TIMESTAMPTZ
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS import_progress;
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+// outboxLockID is the advisory lock that lets a single replica relay the
+// outbox at a time, which keeps per-key ordering across replicas.
+const outboxLockID = 0x6f7574626f78
+
+func (s *Storage) EnqueueOutbox(ctx context.Context, msg storage.OutboxMessage) error {
+	const op = "storage.postgres.EnqueueOutbox"
+	defer observeQuery("enqueue_outbox", time.Now())
+
+	if err := insertOutbox(ctx, s.db, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// enqueue adds the message built by build to the outbox within tx.
+func enqueue(ctx context.Context, tx *sql.Tx, build func() (storage.OutboxMessage, error)) error {
+	msg, err := build()
+	if err != nil {
+		return fmt.Errorf("build outbox message: %w", err)
+	}
+	if err := insertOutbox(ctx, tx, msg); err != nil {
+		return fmt.Errorf("enqueue outbox: %w", err)
+	}
+	return nil
+}
+
+func insertOutbox(ctx context.Context, db execer, msg storage.OutboxMessage) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO outbox (event_id, topic, key, value) VALUES ($1, $2, $3, $4)
+	`, msg.EventID, msg.Topic, msg.Key, msg.Value)
+	return classify(err)
+}
+
+// PendingOutbox returns up to limit unpublished messages in insertion order.
+// A message is skipped while an older message with the same key is backing
+// off, so keys are always published in order.
+func (s *Storage) PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error) {
+	const op = "storage.postgres.PendingOutbox"
+	defer observeQuery("pending_outbox", time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.id, o.event_id, o.topic, o.key, o.value, o.attempts, o.created_at
+		FROM outbox o
+		WHERE o.published_at IS NULL
+		  AND o.next_attempt_at <= now()
+		  AND NOT EXISTS (
+			SELECT 1 FROM outbox e
+			WHERE e.key = o.key AND e.id < o.id
+			  AND e.published_at IS NULL AND e.next_attempt_at > now()
+		  )
+		ORDER BY o.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	defer rows.Close()
+
+	var result []storage.OutboxMessage
+	for rows.Next() {
+		var m storage.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Topic, &m.Key, &m.Value, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, classify(err))
+		}
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterate: %w", op, classify(err))
+	}
+
+	return result, nil
+}
+
+func (s *Storage) MarkOutboxPublished(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkOutboxPublished"
+	defer observeQuery("mark_outbox_published", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return nil
+}
+
+func (s *Storage) MarkOutboxFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error {
+	const op = "storage.postgres.MarkOutboxFailed"
+	defer observeQuery("mark_outbox_failed", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, id, cause.Error(), retryAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return nil
+}
+
+// DeletePublishedOutbox removes messages published before the given time.
+func (s *Storage) DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.postgres.DeletePublishedOutbox"
+	defer observeQuery("delete_published_outbox", time.Now())
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return n, nil
+}
+
+// WithOutboxLock runs fn while holding the outbox advisory lock. It reports
+// false without running fn when another replica holds the lock.
+func (s *Storage) WithOutboxLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	const op = "storage.postgres.WithOutboxLock"
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxLockID).Scan(&locked); err != nil {
+		return false, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, outboxLockID)
+	}()
+
+	return true, fn(ctx)
+}
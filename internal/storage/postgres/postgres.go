@@ -3,44 +3,105 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
 	"github.com/pressly/goose/v3"
+	"github.com/srKazuya/ordersPET/internal/metrics"
 	"github.com/srKazuya/ordersPET/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	ErrOpenDB    = errors.New("failed to open database")
 	ErrMigration = errors.New("failed to run migrations")
+	ErrSchema    = errors.New("database schema is not at the expected version")
 )
 
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var _ storage.Repository = (*Storage)(nil)
+
 type Storage struct {
 	db *sql.DB
+	// schema is the version of the newest embedded migration.
+	schema int64
 }
 
 func New(cfg Config) (*Storage, error) {
 	const op = "storage.postgres.NewStrorage"
 
-	db, err := sql.Open("postgres", cfg.DSN)
+	db, err := otelsql.Open("postgres", cfg.DSN,
+		otelsql.WithAttributes(attribute.String("db.system.name", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w: %w", op, ErrOpenDB, err)
 	}
 
-	if err := goose.Up(db, "internal/storage/postgres/migrations"); err != nil {
+	goose.SetBaseFS(migrations)
+	if err := goose.Up(db, "migrations"); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrMigration, err)
+	}
+
+	all, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrMigration, err)
+	}
+	last, err := all.Last()
+	if err != nil {
 		return nil, fmt.Errorf("%s: %w: %w", op, ErrMigration, err)
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, schema: last.Version}, nil
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("storage.postgres.Ping: %w", classify(err))
+	}
+	return nil
+}
+
+// DB returns the connection pool, so the caller can export its statistics.
+func (s *Storage) DB() *sql.DB {
+	return s.db
+}
+
+// CheckSchema fails when the database is no longer at the newest embedded
+// migration, for example after a manual rollback or a restore from backup.
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	const op = "storage.postgres.CheckSchema"
+
+	version, err := goose.GetDBVersionContext(ctx, s.db)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if version != s.schema {
+		return fmt.Errorf("%s: %w: have %d, want %d", op, ErrSchema, version, s.schema)
+	}
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
 }
 
-func (s *Storage) SaveOrder(ctx context.Context, order *storage.Order) error {
+func (s *Storage) SaveOrder(ctx context.Context, order *storage.Order) (err error) {
 	const op = "storage.postgres.SaveOrder"
+	defer observeQuery("save_order", time.Now())
 
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return fmt.Errorf("%s failed to begin transaction: %w", op, err)
+		return fmt.Errorf("%s failed to begin transaction: %w", op, classify(err))
 	}
 
 	defer func() {
@@ -49,132 +110,365 @@ func (s *Storage) SaveOrder(ctx context.Context, order *storage.Order) error {
 			panic(p)
 		} else if err != nil {
 			_ = tx.Rollback()
-		} else {
-			err = tx.Commit()
+		} else if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("%s commit: %w", op, classify(err))
 		}
 	}()
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO orders (
-			order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-	`, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
-		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+	if order.Status == "" {
+		order.Status = storage.StatusCreated
+	}
+	if !order.Status.Valid() {
+		return fmt.Errorf("%s: %w: %q", op, storage.ErrUnknownStatus, order.Status)
+	}
+
+	hash := storage.Fingerprint(*order)
+
+	res, err := tx.ExecContext(ctx,
+		insertQuery("orders", append(columns(order), "payload_hash"))+" ON CONFLICT (order_uid) DO NOTHING",
+		append(values(order), hash)...)
 	if err != nil {
-		return fmt.Errorf("%s insert into orders: %w", op, err)
+		return fmt.Errorf("%s insert into orders: %w", op, classify(err))
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO deliveries (
-			order_uid, name, phone, zip, city, address, region, email
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-	`, order.OrderUID, order.Delivery.Name, order.Delivery.Phone,
-		order.Delivery.Zip, order.Delivery.City, order.Delivery.Address,
-		order.Delivery.Region, order.Delivery.Email)
+	inserted, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%s insert into deliveries: %w", op, err)
+		return fmt.Errorf("%s insert into orders: %w", op, classify(err))
+	}
+	if inserted == 0 {
+		return s.checkReplay(ctx, tx, order, hash)
 	}
 
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO payments (
-			transaction, order_uid, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-	`, order.Payment.Transaction, order.OrderUID, order.Payment.RequestID,
-		order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
-		order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost,
-		order.Payment.GoodsTotal, order.Payment.CustomFee)
+		INSERT INTO order_status_history (order_uid, from_status, to_status) VALUES ($1, NULL, $2)
+	`, order.OrderUID, order.Status)
+	if err != nil {
+		return fmt.Errorf("%s insert into order_status_history: %w", op, classify(err))
+	}
+
+	_, err = tx.ExecContext(ctx,
+		insertQuery("deliveries", append([]string{"order_uid"}, columns(&order.Delivery)...)),
+		append([]any{order.OrderUID}, values(&order.Delivery)...)...)
+	if err != nil {
+		return fmt.Errorf("%s insert into deliveries: %w", op, classify(err))
+	}
+
+	_, err = tx.ExecContext(ctx,
+		insertQuery("payments", append([]string{"order_uid"}, columns(&order.Payment)...)),
+		append([]any{order.OrderUID}, values(&order.Payment)...)...)
+	if err = classify(err); errors.Is(err, storage.ErrOrderExists) {
+		// The order itself is new, so the transaction is taken.
+		return fmt.Errorf("%s: %w: %s", op, storage.ErrPaymentConflict, order.Payment.Transaction)
+	}
 	if err != nil {
 		return fmt.Errorf("%s insert into payments: %w", op, err)
 	}
 
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO items (
-			order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-	`)
+	stmt, err := tx.PrepareContext(ctx,
+		insertQuery("items", append([]string{"order_uid"}, columns(&storage.Item{})...)))
 	if err != nil {
-		return fmt.Errorf("%s prepare insert items: %w", op, err)
+		return fmt.Errorf("%s prepare insert items: %w", op, classify(err))
 	}
 	defer stmt.Close()
 
 	for _, item := range order.Items {
-		_, err = stmt.ExecContext(ctx,
-			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID,
-			item.Name, item.Sale, item.Size, item.TotalPrice,
-			item.NmID, item.Brand, item.Status)
+		_, err = stmt.ExecContext(ctx, append([]any{order.OrderUID}, values(&item)...)...)
 		if err != nil {
-			return fmt.Errorf("%s insert into items: %w", op, err)
+			return fmt.Errorf("%s insert into items: %w", op, classify(err))
 		}
 	}
 
 	return nil
 }
 
-func (s *Storage) GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error) {
-	const op = "storage.postgres.GetOrderByID"
+// checkReplay decides what an insert that hit an existing order_uid means:
+// the same payload delivered again is a no-op, anything else is a conflict.
+// On a replay the order takes the stored status, so it matches the database.
+func (s *Storage) checkReplay(ctx context.Context, tx *sql.Tx, order *storage.Order, hash string) error {
+	const op = "storage.postgres.SaveOrder"
 
-	order := &storage.Order{}
+	orderUID := order.OrderUID
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		FROM orders WHERE order_uid = $1
-	`, orderUID).Scan(
-		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
-		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-		&order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
+	var (
+		stored sql.NullString
+		status storage.OrderStatus
 	)
+	err := tx.QueryRowContext(ctx, `
+		SELECT payload_hash, status FROM orders WHERE order_uid = $1
+	`, orderUID).Scan(&stored, &status)
 	if err != nil {
-		return storage.Order{}, fmt.Errorf("%s: fetch order: %w", op, err)
+		return fmt.Errorf("%s: fetch payload hash: %w", op, classify(err))
 	}
 
-	err = s.db.QueryRowContext(ctx, `
-		SELECT name, phone, zip, city, address, region, email
-		FROM deliveries WHERE order_uid = $1
-	`, orderUID).Scan(
-		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
-		&order.Delivery.City, &order.Delivery.Address,
-		&order.Delivery.Region, &order.Delivery.Email,
-	)
+	// Rows written before payload hashes existed are compared field by field.
+	if !stored.Valid {
+		existing, err := loadOrders(ctx, tx, []string{orderUID})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(existing) == 0 {
+			return fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
+		}
+		stored.String = storage.Fingerprint(existing[0])
+	}
+
+	if stored.String != hash {
+		return fmt.Errorf("%s: %w: %s", op, storage.ErrOrderConflict, orderUID)
+	}
+
+	order.Status = status
+	return nil
+}
+
+func (s *Storage) GetOrderByUID(ctx context.Context, orderUID string) (storage.Order, error) {
+	const op = "storage.postgres.GetOrderByID"
+	defer observeQuery("get_order", time.Now())
+
+	var orders []storage.Order
+	err := s.readSnapshot(ctx, func(tx *sql.Tx) (err error) {
+		orders, err = loadOrders(ctx, tx, []string{orderUID})
+		return err
+	})
 	if err != nil {
-		return storage.Order{}, fmt.Errorf("%s: fetch delivery: %w", op, err)
+		return storage.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(orders) == 0 {
+		return storage.Order{}, fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
 	}
 
-	err = s.db.QueryRowContext(ctx, `
-		SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-		FROM payments WHERE order_uid = $1
-	`, orderUID).Scan(
-		&order.Payment.Transaction, &order.Payment.RequestID,
-		&order.Payment.Currency, &order.Payment.Provider,
-		&order.Payment.Amount, &order.Payment.PaymentDT,
-		&order.Payment.Bank, &order.Payment.DeliveryCost,
-		&order.Payment.GoodsTotal, &order.Payment.CustomFee,
-	)
+	return orders[0], nil
+}
+
+// GetOrdersByUIDs loads the given orders from one snapshot in a fixed number
+// of queries. Orders are returned in the order of orderUIDs; unknown ones
+// are left out.
+func (s *Storage) GetOrdersByUIDs(ctx context.Context, orderUIDs []string) ([]storage.Order, error) {
+	const op = "storage.postgres.GetOrdersByUIDs"
+	defer observeQuery("get_orders", time.Now())
+
+	if len(orderUIDs) == 0 {
+		return nil, nil
+	}
+
+	var orders []storage.Order
+	err := s.readSnapshot(ctx, func(tx *sql.Tx) (err error) {
+		orders, err = loadOrders(ctx, tx, orderUIDs)
+		return err
+	})
 	if err != nil {
-		return storage.Order{}, fmt.Errorf("%s: fetch payment: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items WHERE order_uid = $1
-	`, orderUID)
+	return orders, nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// readSnapshot runs fn in a read-only repeatable read transaction, so every
+// query fn makes sees the same snapshot.
+func (s *Storage) readSnapshot(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
 	if err != nil {
-		return storage.Order{}, fmt.Errorf("%s: fetch items: %w", op, err)
+		return fmt.Errorf("begin transaction: %w", classify(err))
+	}
+	// Nothing is written, so rolling back only releases the snapshot.
+	defer func() { _ = tx.Rollback() }()
+
+	return fn(tx)
+}
+
+// loadOrders fetches orders with their delivery and payment in one query and
+// all their items in another, whatever the number of orders. The result
+// follows the order of orderUIDs; unknown ones are left out.
+func loadOrders(ctx context.Context, q querier, orderUIDs []string) ([]storage.Order, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+selectList(qualified("o", columns(&storage.Order{})))+`,
+			`+selectList(qualified("d", columns(&storage.Delivery{})))+`,
+			`+selectList(qualified("p", columns(&storage.Payment{})))+`
+		FROM orders o
+		JOIN deliveries d ON d.order_uid = o.order_uid
+		JOIN payments p ON p.order_uid = o.order_uid
+		WHERE o.order_uid = ANY($1)
+	`, pq.Array(orderUIDs))
+	if err != nil {
+		return nil, fmt.Errorf("fetch orders: %w", classify(err))
 	}
 	defer rows.Close()
 
+	found := make([]storage.Order, 0, len(orderUIDs))
+	index := make(map[string]int, len(orderUIDs))
 	for rows.Next() {
-		var item storage.Item
-		err := rows.Scan(
-			&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID,
-			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
-			&item.NmID, &item.Brand, &item.Status,
+		var order storage.Order
+		scan := append(dest(&order), dest(&order.Delivery)...)
+		scan = append(scan, dest(&order.Payment)...)
+		if err := rows.Scan(scan...); err != nil {
+			return nil, fmt.Errorf("scan order: %w", classify(err))
+		}
+		index[order.OrderUID] = len(found)
+		found = append(found, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate orders: %w", classify(err))
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	itemRows, err := q.QueryContext(ctx, `
+		SELECT order_uid, `+selectList(columns(&storage.Item{}))+`
+		FROM items
+		WHERE order_uid = ANY($1)
+		ORDER BY id
+	`, pq.Array(orderUIDs))
+	if err != nil {
+		return nil, fmt.Errorf("fetch items: %w", classify(err))
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var (
+			orderUID string
+			item     storage.Item
 		)
+		if err := itemRows.Scan(append([]any{&orderUID}, dest(&item)...)...); err != nil {
+			return nil, fmt.Errorf("scan item: %w", classify(err))
+		}
+		if i, ok := index[orderUID]; ok {
+			found[i].Items = append(found[i].Items, item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate items: %w", classify(err))
+	}
+
+	orders := make([]storage.Order, 0, len(found))
+	for _, uid := range orderUIDs {
+		if i, ok := index[uid]; ok {
+			orders = append(orders, found[i])
+		}
+	}
+	return orders, nil
+}
+
+func (s *Storage) UpdateOrderStatus(ctx context.Context, orderUID string, to storage.OrderStatus) (storage.StatusChange, error) {
+	return s.UpdateOrderStatusWithOutbox(ctx, orderUID, to, nil)
+}
+
+// UpdateOrderStatusWithOutbox changes the status like UpdateOrderStatus and,
+// unless announce is nil, enqueues the outbox message it builds for the
+// change in the same transaction, so the change is never committed without
+// its event.
+func (s *Storage) UpdateOrderStatusWithOutbox(ctx context.Context, orderUID string, to storage.OrderStatus, announce func(storage.StatusChange) (storage.OutboxMessage, error)) (change storage.StatusChange, err error) {
+	const op = "storage.postgres.UpdateOrderStatus"
+	defer observeQuery("update_order_status", time.Now())
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s failed to begin transaction: %w", op, classify(err))
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("%s commit: %w", op, classify(err))
+		}
+	}()
+
+	change = storage.StatusChange{OrderUID: orderUID, To: to}
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, COALESCE(customer_id, ''), COALESCE(shardkey, '') FROM orders WHERE order_uid = $1 FOR UPDATE
+	`, orderUID).Scan(&change.From, &change.CustomerID, &change.ShardKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
+	}
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: fetch status: %w", op, classify(err))
+	}
+
+	if err = storage.CheckTransition(change.From, to); err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE orders SET status = $2 WHERE order_uid = $1
+	`, orderUID, to)
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: update status: %w", op, classify(err))
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO order_status_history (order_uid, from_status, to_status)
+		VALUES ($1, $2, $3)
+		RETURNING changed_at
+	`, orderUID, change.From, to).Scan(&change.ChangedAt)
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: insert into order_status_history: %w", op, classify(err))
+	}
+
+	if announce != nil {
+		if err = enqueue(ctx, tx, func() (storage.OutboxMessage, error) { return announce(change) }); err != nil {
+			return storage.StatusChange{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return change, nil
+}
+
+// DeleteOrder removes the order together with its delivery, payment, items
+// and status history.
+func (s *Storage) DeleteOrder(ctx context.Context, orderUID string) error {
+	return s.DeleteOrderWithOutbox(ctx, orderUID, nil)
+}
+
+// DeleteOrderWithOutbox removes the order like DeleteOrder and, unless
+// announce is nil, enqueues the outbox message it builds in the same
+// transaction. announce gets the order_uid, customer_id and shardkey of the
+// deleted order, enough to key its event.
+func (s *Storage) DeleteOrderWithOutbox(ctx context.Context, orderUID string, announce func(storage.Order) (storage.OutboxMessage, error)) (err error) {
+	const op = "storage.postgres.DeleteOrder"
+	defer observeQuery("delete_order", time.Now())
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s failed to begin transaction: %w", op, classify(err))
+	}
+
+	defer func() {
 		if err != nil {
-			return storage.Order{}, fmt.Errorf("%s: scan item: %w", op, err)
+			_ = tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("%s commit: %w", op, classify(err))
 		}
-		order.Items = append(order.Items, item)
+	}()
+
+	deleted := storage.Order{OrderUID: orderUID}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM orders WHERE order_uid = $1
+		RETURNING COALESCE(customer_id, ''), COALESCE(shardkey, '')
+	`, orderUID).Scan(&deleted.CustomerID, &deleted.ShardKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w: %s", op, storage.ErrOrderNotFound, orderUID)
 	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	if announce != nil {
+		if err = enqueue(ctx, tx, func() (storage.OutboxMessage, error) { return announce(deleted) }); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
 
-	return *order, nil
+func observeQuery(query string, start time.Time) {
+	metrics.DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
 }
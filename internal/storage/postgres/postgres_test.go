@@ -0,0 +1,38 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/postgres"
+	"github.com/srKazuya/ordersPET/internal/storage/storagetest"
+)
+
+// testDSNEnv names the database the suite runs against. Its order tables are
+// emptied before every subtest, so never point it at real data.
+const testDSNEnv = "ORDERS_TEST_POSTGRES_DSN"
+
+func TestRepository(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Repository {
+		s, err := postgres.New(postgres.Config{DSN: dsn})
+		if err != nil {
+			t.Fatalf("postgres.New: %v", err)
+		}
+
+		_, err = s.DB().ExecContext(context.Background(), `
+			TRUNCATE orders, deliveries, payments, items, order_status_history RESTART IDENTITY CASCADE
+		`)
+		if err != nil {
+			_ = s.Close()
+			t.Fatalf("truncate: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Storage drivers selectable with storage.driver.
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// Repository is the full order store. postgres.Storage and memory.Storage
+// implement it with the same semantics, which storagetest checks: saving is
+// idempotent for an identical payload and fails with ErrOrderConflict for a
+// different one, or with ErrPaymentConflict when a new order reuses a payment
+// transaction, lookups of unknown orders fail with ErrOrderNotFound,
+// listings are newest first and status changes follow CheckTransition.
+type Repository interface {
+	SaveOrder(ctx context.Context, order *Order) error
+	GetOrderByUID(ctx context.Context, orderUID string) (Order, error)
+	GetOrdersByUIDs(ctx context.Context, orderUIDs []string) ([]Order, error)
+	ListOrders(ctx context.Context, params ListParams) (OrdersPage, error)
+	ExportOrders(ctx context.Context, f OrderFilter, fn func(Order) error) error
+	UpdateOrderStatus(ctx context.Context, orderUID string, to OrderStatus) (StatusChange, error)
+	DeleteOrder(ctx context.Context, orderUID string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var ErrUnknownDriver = errors.New("unknown storage driver")
+
+func ParseDriver(s string) (string, error) {
+	switch s {
+	case DriverPostgres, DriverMemory:
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownDriver, s)
+}
@@ -4,56 +4,62 @@ import (
 	"time"
 )
 
+// Order is the single order model. Its json tags define the HTTP API and the
+// Kafka event payload, validate tags the accepted input and db tags the
+// PostgreSQL columns, which storage/postgres maps by reflection. Adding a
+// field means adding it here and to a migration.
 type Order struct {
-	OrderUID          string    `json:"order_uid" validate:"required,alphanum"`
-	TrackNumber       string    `json:"track_number" validate:"required"`
-	Entry             string    `json:"entry" validate:"required"`
+	OrderUID          string    `json:"order_uid" validate:"required,alphanum" db:"order_uid"`
+	TrackNumber       string    `json:"track_number" validate:"required" db:"track_number"`
+	Entry             string    `json:"entry" validate:"required" db:"entry"`
 	Delivery          Delivery  `json:"delivery" validate:"required,dive"`
 	Payment           Payment   `json:"payment" validate:"required,dive"`
 	Items             []Item    `json:"items" validate:"required,min=1,dive"`
-	Locale            string    `json:"locale" validate:"required,alpha"`
-	InternalSignature string    `json:"internal_signature"`
-	CustomerID        string    `json:"customer_id" validate:"required"`
-	DeliveryService   string    `json:"delivery_service" validate:"required"`
-	ShardKey          string    `json:"shardkey" validate:"required"`
-	SmID              int       `json:"sm_id" validate:"required"`
-	DateCreated       time.Time `json:"date_created" validate:"required"`
-	OofShard          string    `json:"oof_shard" validate:"required"`
+	Locale            string    `json:"locale" validate:"required,alpha" db:"locale"`
+	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
+	CustomerID        string    `json:"customer_id" validate:"required" db:"customer_id"`
+	DeliveryService   string    `json:"delivery_service" validate:"required" db:"delivery_service"`
+	ShardKey          string    `json:"shardkey" validate:"required" db:"shardkey"`
+	SmID              int       `json:"sm_id" validate:"required" db:"sm_id"`
+	DateCreated       time.Time `json:"date_created" validate:"required" db:"date_created"`
+	OofShard          string    `json:"oof_shard" validate:"required" db:"oof_shard"`
+
+	Status OrderStatus `json:"status,omitempty" db:"status"`
 }
 
 type Delivery struct {
-	Name    string `json:"name" validate:"required"`
-	Phone   string `json:"phone" validate:"required,e164"`
-	Zip     string `json:"zip" validate:"required"`
-	City    string `json:"city" validate:"required"`
-	Address string `json:"address" validate:"required"`
-	Region  string `json:"region" validate:"required"`
-	Email   string `json:"email" validate:"required,email"`
+	Name    string `json:"name" validate:"required" db:"name"`
+	Phone   string `json:"phone" validate:"required,e164" db:"phone"`
+	Zip     string `json:"zip" validate:"required" db:"zip"`
+	City    string `json:"city" validate:"required" db:"city"`
+	Address string `json:"address" validate:"required" db:"address"`
+	Region  string `json:"region" validate:"required" db:"region"`
+	Email   string `json:"email" validate:"required,email" db:"email"`
 }
 
 type Payment struct {
-	Transaction  string `json:"transaction" validate:"required"`
-	RequestID    string `json:"request_id"`
-	Currency     string `json:"currency" validate:"required,len=3"`
-	Provider     string `json:"provider" validate:"required"`
-	Amount       int    `json:"amount" validate:"required,gt=0"`
-	PaymentDT    int64  `json:"payment_dt" validate:"required"`
-	Bank         string `json:"bank" validate:"required"`
-	DeliveryCost int    `json:"delivery_cost" validate:"required"`
-	GoodsTotal   int    `json:"goods_total" validate:"required"`
-	CustomFee    int    `json:"custom_fee"`
+	Transaction  string `json:"transaction" validate:"required" db:"transaction"`
+	RequestID    string `json:"request_id" db:"request_id"`
+	Currency     string `json:"currency" validate:"required,len=3" db:"currency"`
+	Provider     string `json:"provider" validate:"required" db:"provider"`
+	Amount       int    `json:"amount" validate:"required,gt=0" db:"amount"`
+	PaymentDT    int64  `json:"payment_dt" validate:"required" db:"payment_dt"`
+	Bank         string `json:"bank" validate:"required" db:"bank"`
+	DeliveryCost int    `json:"delivery_cost" validate:"required" db:"delivery_cost"`
+	GoodsTotal   int    `json:"goods_total" validate:"required" db:"goods_total"`
+	CustomFee    int    `json:"custom_fee" db:"custom_fee"`
 }
 
 type Item struct {
-	ChrtID      int    `json:"chrt_id" validate:"required"`
-	TrackNumber string `json:"track_number" validate:"required"`
-	Price       int    `json:"price" validate:"required,gt=0"`
-	RID         string `json:"rid" validate:"required"`
-	Name        string `json:"name" validate:"required"`
-	Sale        int    `json:"sale" validate:"gte=0"`
-	Size        string `json:"size" validate:"required"`
-	TotalPrice  int    `json:"total_price" validate:"required,gt=0"`
-	NmID        int    `json:"nm_id" validate:"required"`
-	Brand       string `json:"brand" validate:"required"`
-	Status      int    `json:"status" validate:"required"`
+	ChrtID      int    `json:"chrt_id" validate:"required" db:"chrt_id"`
+	TrackNumber string `json:"track_number" validate:"required" db:"track_number"`
+	Price       int    `json:"price" validate:"required,gt=0" db:"price"`
+	RID         string `json:"rid" validate:"required" db:"rid"`
+	Name        string `json:"name" validate:"required" db:"name"`
+	Sale        int    `json:"sale" validate:"gte=0" db:"sale"`
+	Size        string `json:"size" validate:"required" db:"size"`
+	TotalPrice  int    `json:"total_price" validate:"required,gt=0" db:"total_price"`
+	NmID        int    `json:"nm_id" validate:"required" db:"nm_id"`
+	Brand       string `json:"brand" validate:"required" db:"brand"`
+	Status      int    `json:"status" validate:"required" db:"status"`
 }
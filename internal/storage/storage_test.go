@@ -0,0 +1,72 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+	"github.com/srKazuya/ordersPET/internal/storage/storagetest"
+)
+
+func TestOrderJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		order storage.Order
+	}{
+		{name: "full", order: storagetest.FullOrder()},
+		{name: "without status", order: func() storage.Order {
+			order := storagetest.FullOrder()
+			order.Status = ""
+			return order
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.order)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var got storage.Order
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			if !got.DateCreated.Equal(tt.order.DateCreated) {
+				t.Errorf("date_created = %v, want %v", got.DateCreated, tt.order.DateCreated)
+			}
+			got.DateCreated = tt.order.DateCreated
+			if !reflect.DeepEqual(got, tt.order) {
+				t.Errorf("round trip changed the order\n got: %+v\nwant: %+v", got, tt.order)
+			}
+		})
+	}
+}
+
+func TestOrderJSONFieldNames(t *testing.T) {
+	raw, err := json.Marshal(storagetest.FullOrder())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	// The API and event payload field names are a contract with clients.
+	for _, key := range []string{
+		"order_uid", "track_number", "entry", "delivery", "payment", "items", "locale",
+		"internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id",
+		"date_created", "oof_shard", "status",
+	} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("field %q is missing from %s", key, raw)
+		}
+	}
+	if len(doc) != 15 {
+		t.Errorf("order has %d fields, want 15: %s", len(doc), raw)
+	}
+}
@@ -0,0 +1,418 @@
+// Package storagetest is the conformance suite for storage.Repository. Every
+// backend must pass it, which keeps the in-memory store a faithful stand-in
+// for PostgreSQL:
+//
+//	func TestRepository(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Repository { return memory.New() })
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/srKazuya/ordersPET/internal/storage"
+)
+
+// Factory returns an empty repository for one subtest.
+type Factory func(t *testing.T) storage.Repository
+
+// Run checks that the repositories made by newRepo behave like
+// storage.Repository documents.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo storage.Repository)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveUnknownStatus", testSaveUnknownStatus},
+		{"SaveDuplicate", testSaveDuplicate},
+		{"SaveConflict", testSaveConflict},
+		{"SavePaymentConflict", testSavePaymentConflict},
+		{"GetNotFound", testGetNotFound},
+		{"GetOrdersByUIDs", testGetOrdersByUIDs},
+		{"ListOrders", testListOrders},
+		{"ListFilters", testListFilters},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"ExportOrders", testExportOrders},
+		{"UpdateOrderStatus", testUpdateOrderStatus},
+		{"DeleteOrder", testDeleteOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			t.Cleanup(func() { _ = repo.Close() })
+			tt.fn(t, repo)
+		})
+	}
+}
+
+// Order returns a valid order that passes the tag and business rules.
+func Order(uid string, created time.Time) storage.Order {
+	return storage.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: storage.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: storage.Payment{
+			Transaction:  "tx" + uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []storage.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     created,
+		OofShard:        "1",
+	}
+}
+
+// FullOrder is Order with every optional field set too, so encoding and
+// mapping tests notice a field that gets dropped.
+func FullOrder() storage.Order {
+	order := Order("b563feb7b2b84b6test", time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC))
+	order.InternalSignature = "sig"
+	order.Payment.RequestID = "req"
+	order.Payment.CustomFee = 7
+	order.Payment.Amount += order.Payment.CustomFee
+	order.Status = storage.StatusPaid
+	return order
+}
+
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func save(t *testing.T, repo storage.Repository, orders ...storage.Order) {
+	t.Helper()
+	for _, o := range orders {
+		if err := repo.SaveOrder(context.Background(), &o); err != nil {
+			t.Fatalf("SaveOrder(%s): %v", o.OrderUID, err)
+		}
+	}
+}
+
+func assertOrder(t *testing.T, want, got storage.Order) {
+	t.Helper()
+	if !want.DateCreated.Equal(got.DateCreated) {
+		t.Errorf("order %s: date_created = %v, want %v", want.OrderUID, got.DateCreated, want.DateCreated)
+	}
+	want.DateCreated, got.DateCreated = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("order %s:\n got %+v\nwant %+v", want.OrderUID, got, want)
+	}
+}
+
+func assertUIDs(t *testing.T, orders []storage.Order, want ...string) {
+	t.Helper()
+	got := make([]string, len(orders))
+	for i, o := range orders {
+		got[i] = o.OrderUID
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("order uids = %v, want %v", got, want)
+	}
+}
+
+func testSaveAndGet(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	order := Order("a1", base)
+	order.Items = append(order.Items, order.Items[0])
+	order.Items[1].ChrtID = 1
+
+	if err := repo.SaveOrder(ctx, &order); err != nil {
+		t.Fatalf("SaveOrder: %v", err)
+	}
+	if order.Status != storage.StatusCreated {
+		t.Errorf("status after save = %q, want %q", order.Status, storage.StatusCreated)
+	}
+
+	got, err := repo.GetOrderByUID(ctx, "a1")
+	if err != nil {
+		t.Fatalf("GetOrderByUID: %v", err)
+	}
+	assertOrder(t, order, got)
+}
+
+func testSaveUnknownStatus(t *testing.T, repo storage.Repository) {
+	order := Order("a1", base)
+	order.Status = "lost"
+
+	err := repo.SaveOrder(context.Background(), &order)
+	if !errors.Is(err, storage.ErrUnknownStatus) {
+		t.Fatalf("SaveOrder error = %v, want ErrUnknownStatus", err)
+	}
+}
+
+func testSaveDuplicate(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("a1", base))
+
+	if _, err := repo.UpdateOrderStatus(ctx, "a1", storage.StatusPaid); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+
+	// A redelivery is a no-op that reports the stored status.
+	again := Order("a1", base)
+	if err := repo.SaveOrder(ctx, &again); err != nil {
+		t.Fatalf("SaveOrder of a duplicate: %v", err)
+	}
+	if again.Status != storage.StatusPaid {
+		t.Errorf("status after duplicate save = %q, want %q", again.Status, storage.StatusPaid)
+	}
+}
+
+func testSaveConflict(t *testing.T, repo storage.Repository) {
+	save(t, repo, Order("a1", base))
+
+	other := Order("a1", base)
+	other.TrackNumber = "OTHER"
+
+	err := repo.SaveOrder(context.Background(), &other)
+	if !errors.Is(err, storage.ErrOrderConflict) {
+		t.Fatalf("SaveOrder error = %v, want ErrOrderConflict", err)
+	}
+}
+
+func testSavePaymentConflict(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("a1", base))
+
+	other := Order("a2", base)
+	other.Payment.Transaction = "txa1"
+
+	err := repo.SaveOrder(ctx, &other)
+	if !errors.Is(err, storage.ErrPaymentConflict) {
+		t.Fatalf("SaveOrder error = %v, want ErrPaymentConflict", err)
+	}
+	if _, err := repo.GetOrderByUID(ctx, "a2"); !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("GetOrderByUID after a failed save = %v, want ErrOrderNotFound", err)
+	}
+
+	// Deleting the holder frees the transaction.
+	if err := repo.DeleteOrder(ctx, "a1"); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	save(t, repo, other)
+}
+
+func testGetNotFound(t *testing.T, repo storage.Repository) {
+	_, err := repo.GetOrderByUID(context.Background(), "missing")
+	if !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Fatalf("GetOrderByUID error = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func testGetOrdersByUIDs(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("a1", base), Order("a2", base), Order("a3", base))
+
+	orders, err := repo.GetOrdersByUIDs(ctx, []string{"a3", "missing", "a1"})
+	if err != nil {
+		t.Fatalf("GetOrdersByUIDs: %v", err)
+	}
+	assertUIDs(t, orders, "a3", "a1")
+	for _, o := range orders {
+		if len(o.Items) != 1 {
+			t.Errorf("order %s has %d items, want 1", o.OrderUID, len(o.Items))
+		}
+	}
+
+	orders, err = repo.GetOrdersByUIDs(ctx, nil)
+	if err != nil || len(orders) != 0 {
+		t.Fatalf("GetOrdersByUIDs(nil) = %v, %v; want no orders", orders, err)
+	}
+}
+
+func testListOrders(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo,
+		Order("a1", base),
+		Order("a2", base.Add(time.Hour)),
+		Order("a3", base.Add(time.Hour)),
+		Order("a4", base.Add(2*time.Hour)),
+		Order("a5", base.Add(-time.Hour)),
+	)
+
+	var pages [][]storage.Order
+	params := storage.ListParams{Limit: 2}
+	for {
+		page, err := repo.ListOrders(ctx, params)
+		if err != nil {
+			t.Fatalf("ListOrders: %v", err)
+		}
+		pages = append(pages, page.Orders)
+		if page.NextCursor == "" {
+			break
+		}
+		if len(pages) > 5 {
+			t.Fatal("ListOrders does not stop paging")
+		}
+		params.Cursor = page.NextCursor
+	}
+
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	assertUIDs(t, pages[0], "a4", "a3")
+	assertUIDs(t, pages[1], "a2", "a1")
+	assertUIDs(t, pages[2], "a5")
+
+	page, err := repo.ListOrders(ctx, storage.ListParams{Filter: storage.OrderFilter{CustomerID: "nobody"}})
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if page.Orders == nil || len(page.Orders) != 0 || page.NextCursor != "" {
+		t.Errorf("empty listing = %+v, want an empty non-nil page", page)
+	}
+}
+
+func testListFilters(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+
+	other := Order("b2", base.Add(time.Hour))
+	other.CustomerID = "other"
+	other.Payment.Currency = "EUR"
+	other.Items[0].Brand = "Acme"
+
+	save(t, repo, Order("b1", base), other, Order("b3", base.Add(2*time.Hour)))
+
+	tests := []struct {
+		filter storage.OrderFilter
+		want   []string
+	}{
+		{storage.OrderFilter{CustomerID: "other"}, []string{"b2"}},
+		{storage.OrderFilter{Currency: "USD"}, []string{"b3", "b1"}},
+		{storage.OrderFilter{Brand: "Acme"}, []string{"b2"}},
+		{storage.OrderFilter{DateFrom: base.Add(time.Hour)}, []string{"b3", "b2"}},
+		{storage.OrderFilter{DateTo: base.Add(time.Hour)}, []string{"b1"}},
+		{storage.OrderFilter{Locale: "en", DeliveryService: "meest", Provider: "wbpay"}, []string{"b3", "b2", "b1"}},
+	}
+	for _, tt := range tests {
+		page, err := repo.ListOrders(ctx, storage.ListParams{Filter: tt.filter})
+		if err != nil {
+			t.Fatalf("ListOrders(%+v): %v", tt.filter, err)
+		}
+		assertUIDs(t, page.Orders, tt.want...)
+	}
+}
+
+func testListInvalidCursor(t *testing.T, repo storage.Repository) {
+	_, err := repo.ListOrders(context.Background(), storage.ListParams{Cursor: "not-a-cursor"})
+	if !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Fatalf("ListOrders error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func testExportOrders(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("c1", base), Order("c2", base.Add(time.Hour)), Order("c3", base.Add(-time.Hour)))
+
+	var exported []storage.Order
+	err := repo.ExportOrders(ctx, storage.OrderFilter{}, func(o storage.Order) error {
+		exported = append(exported, o)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportOrders: %v", err)
+	}
+	assertUIDs(t, exported, "c2", "c1", "c3")
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.ExportOrders(ctx, storage.OrderFilter{}, func(storage.Order) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ExportOrders = %v after %d calls, want the callback error after 1", err, calls)
+	}
+}
+
+func testUpdateOrderStatus(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("d1", base))
+
+	change, err := repo.UpdateOrderStatus(ctx, "d1", storage.StatusPaid)
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+	if change.From != storage.StatusCreated || change.To != storage.StatusPaid ||
+		change.CustomerID != "test" || change.ShardKey != "9" || change.ChangedAt.IsZero() {
+		t.Errorf("change = %+v", change)
+	}
+
+	got, err := repo.GetOrderByUID(ctx, "d1")
+	if err != nil {
+		t.Fatalf("GetOrderByUID: %v", err)
+	}
+	if got.Status != storage.StatusPaid {
+		t.Errorf("stored status = %q, want %q", got.Status, storage.StatusPaid)
+	}
+
+	var transitionErr *storage.TransitionError
+	_, err = repo.UpdateOrderStatus(ctx, "d1", storage.StatusCreated)
+	if !errors.As(err, &transitionErr) {
+		t.Errorf("illegal transition error = %v, want *TransitionError", err)
+	}
+
+	_, err = repo.UpdateOrderStatus(ctx, "missing", storage.StatusPaid)
+	if !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("UpdateOrderStatus of a missing order = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func testDeleteOrder(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	save(t, repo, Order("e1", base), Order("e2", base))
+
+	if err := repo.DeleteOrder(ctx, "e1"); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	if _, err := repo.GetOrderByUID(ctx, "e1"); !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("GetOrderByUID after delete = %v, want ErrOrderNotFound", err)
+	}
+	if err := repo.DeleteOrder(ctx, "e1"); !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("second DeleteOrder = %v, want ErrOrderNotFound", err)
+	}
+
+	// The uid is free again after a delete.
+	save(t, repo, Order("e1", base))
+
+	page, err := repo.ListOrders(ctx, storage.ListParams{})
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	assertUIDs(t, page.Orders, "e2", "e1")
+}